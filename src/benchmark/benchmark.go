@@ -0,0 +1,253 @@
+package benchmark
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+	"path/filepath"
+)
+
+import (
+	"pixel_restoration/degradation"
+	"pixel_restoration/evaluation"
+	"pixel_restoration/images"
+	"pixel_restoration/restore"
+	"pixel_restoration/types"
+)
+
+/*
+	Defines a set of sweeps, each sweep varies one degradation while the others stay disabled
+*/
+const (
+	SWEEP_NOISE uint8 = iota
+	SWEEP_JPEG uint8 = iota
+	SWEEP_SCALE uint8 = iota
+)
+
+var sweep_names = [3]string{"noise", "jpeg_quality", "scale"}
+
+/*
+	Config describes generated benchmark.
+
+	Seed: int64
+		Base seed, the same config always generates the same images
+	CasesPerPoint: int
+		Number of random cases (sprite, pixel size, grid width) generated.
+		The same cases are reused for every point of every sweep, so curves compare identical inputs.
+	MinPixel, MaxPixel: uint
+		Inclusive range of random pixel sizes
+	MaxGrid: uint
+		Largest random gridline width, width is chosen from 0 to MaxGrid inclusive
+	MaxImageSize: int
+		Upper limit on upscaled image dimension (before the scale sweep), sprite size is chosen to fit it.
+		Pixel sizes and gridline widths too large for a sprite of 8 art pixels are reduced to fit.
+	NoiseLevels, JPEGQualities, ScaleFactors:
+		Values of each sweep. Noise level is sigma of gaussian noise, JPEG quality 0 means no compression.
+	Tolerance:
+		Allowed differences of guessed sizes, see evaluation.Tolerance
+	SaveDir: string
+		If not empty, every generated image is saved there with ground truth encoded in the file name
+
+	Constraints:
+		CasesPerPoint >= 1
+		1 <= MinPixel <= MaxPixel
+		MaxImageSize >= 8
+*/
+type Config struct {
+	Seed int64
+	CasesPerPoint int
+	MinPixel uint
+	MaxPixel uint
+	MaxGrid uint
+	MaxImageSize int
+	NoiseLevels []float64
+	JPEGQualities []int
+	ScaleFactors []float64
+	Tolerance evaluation.Tolerance
+	SaveDir string
+}
+
+func GetBaseConfig() Config {
+	return Config{
+		Seed: 1,
+		CasesPerPoint: 100,
+		MinPixel: 1,
+		MaxPixel: 64,
+		MaxGrid: 4,
+		MaxImageSize: 512,
+		NoiseLevels: []float64{0, 5, 10, 20, 30, 45},
+		JPEGQualities: []int{0, 95, 80, 60, 40, 20},
+		ScaleFactors: []float64{1.0, 0.8, 1.25, 1.5, 1.75, 2.33},
+		Tolerance: evaluation.GetBaseTolerance(),
+		SaveDir: "",
+	}
+}
+
+/*
+	Randomly chosen base of a benchmark case, shared by all sweep points
+*/
+type benchmarkCase struct {
+	sprite *image.RGBA
+	pixel_size uint
+	grid_size uint
+	seed int64
+}
+
+/*
+	CaseResult holds outcome of one generated image
+*/
+type CaseResult struct {
+	Sweep string `json:"sweep"`
+	Value float64 `json:"value"`
+	Case int `json:"case"`
+	PixelSize uint `json:"pixel_size"`
+	GridSize uint `json:"grid_size"`
+	Truth degradation.GroundTruth `json:"truth"`
+
+	RowsPixel types.IntervalRangeEntry `json:"rows_pixel"`
+	RowsGrid types.IntervalRangeEntry `json:"rows_grid"`
+	ColsPixel types.IntervalRangeEntry `json:"cols_pixel"`
+	ColsGrid types.IntervalRangeEntry `json:"cols_grid"`
+
+	PixelPass bool `json:"pixel_pass"`
+	GridPass bool `json:"grid_pass"`
+	SizePass bool `json:"size_pass"`
+	Strategy string `json:"strategy,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+/*
+	Run generates all benchmark images described by config, runs restoration pipeline on each of them
+	and aggregates results into accuracy curves and failure envelope.
+*/
+func Run(config Config, options restore.Options) (Report, error) {
+	cases := generateCases(config)
+	results := make([]CaseResult, 0, len(cases) * 3 * 6)
+
+	for sweep := SWEEP_NOISE; sweep <= SWEEP_SCALE; sweep++ {
+		for _, value := range sweepValues(config, sweep) {
+			for case_id, benchmark_case := range cases {
+				params := caseParams(benchmark_case, sweep, value)
+				result, err := runCase(config, options, benchmark_case, params, sweep, value, case_id)
+				if err != nil {
+					return Report{}, err
+				}
+				results = append(results, result)
+			}
+		}
+	}
+
+	return buildReport(results), nil
+}
+
+/*
+	Generates random cases, each case gets its own rng derived from config seed and case index
+*/
+func generateCases(config Config) []benchmarkCase {
+	cases := make([]benchmarkCase, config.CasesPerPoint)
+	pixel_range := int(config.MaxPixel - config.MinPixel) + 1
+
+	for i := range cases {
+		case_seed := config.Seed * 1000003 + int64(i)
+		rng := rand.New(rand.NewSource(case_seed))
+
+		pixel_size := config.MinPixel + uint(rng.Intn(pixel_range))
+		grid_size := uint(rng.Intn(int(config.MaxGrid) + 1))
+
+		// upscaled image of n art pixels is n * (pixel + grid) + grid long, sizes are reduced until 8 art pixels fit
+		grid_size = min(grid_size, uint(max((config.MaxImageSize - 8) / 9, 0)))
+		pixel_size = max(min(pixel_size, uint(max((config.MaxImageSize - int(grid_size)) / 8 - int(grid_size), 0))), 1)
+
+		// sprite size chosen so that upscaled image fits MaxImageSize, but has at least 8 art pixels per axis
+		max_art := max((config.MaxImageSize - int(grid_size)) / int(pixel_size + grid_size), 8)
+		width := 8 + rng.Intn(max_art - 8 + 1)
+		height := 8 + rng.Intn(max_art - 8 + 1)
+		palette_size := 2 + rng.Intn(14)
+
+		cases[i] = benchmarkCase{
+			sprite: GenerateSprite(rng, width, height, palette_size),
+			pixel_size: pixel_size,
+			grid_size: grid_size,
+			seed: case_seed,
+		}
+	}
+	return cases
+}
+
+func sweepValues(config Config, sweep uint8) []float64 {
+	switch sweep {
+	case SWEEP_NOISE:
+		return config.NoiseLevels
+	case SWEEP_JPEG:
+		values := make([]float64, len(config.JPEGQualities))
+		for i, quality := range config.JPEGQualities {
+			values[i] = float64(quality)
+		}
+		return values
+	default:
+		return config.ScaleFactors
+	}
+}
+
+/*
+	Makes degradation params of a case with only the swept degradation enabled
+*/
+func caseParams(benchmark_case benchmarkCase, sweep uint8, value float64) degradation.Params {
+	params := degradation.GetBaseParams()
+	params.PixelSize = benchmark_case.pixel_size
+	params.GridSize = benchmark_case.grid_size
+	params.Seed = benchmark_case.seed
+
+	switch sweep {
+	case SWEEP_NOISE:
+		params.GaussianNoiseSigma = value
+	case SWEEP_JPEG:
+		params.JPEGQuality = int(value)
+	case SWEEP_SCALE:
+		params.ScaleX, params.ScaleY = value, value
+	}
+	return params
+}
+
+func runCase(
+	config Config, options restore.Options, benchmark_case benchmarkCase,
+	params degradation.Params, sweep uint8, value float64, case_id int,
+) (result CaseResult, err error) {
+	result.Sweep = sweep_names[sweep]
+	result.Value = value
+	result.Case = case_id
+	result.PixelSize, result.GridSize = params.PixelSize, params.GridSize
+
+	img, truth, err := degradation.Degrade(benchmark_case.sprite, params)
+	if err != nil {
+		return result, err
+	}
+	result.Truth = truth
+
+	if config.SaveDir != "" {
+		name := fmt.Sprintf("%s_%g_case%d", result.Sweep, value, case_id)
+		path := filepath.Join(config.SaveDir, degradation.TestFilename(truth, name))
+		if err := images.RGBASaveToFile(path, img); err != nil {
+			return result, err
+		}
+	}
+
+	restored, restore_err := restore.Restore(img, options)
+	if restore_err != nil {
+		result.Error = restore_err.Error()
+		return result, nil
+	}
+
+	result.Strategy = restore.StrategyName(restored.Strategy)
+	result.RowsPixel, result.RowsGrid = restored.Rows.PixelGuess, restored.Rows.GridGuess
+	result.ColsPixel, result.ColsGrid = restored.Cols.PixelGuess, restored.Cols.GridGuess
+
+	tolerance := config.Tolerance
+	result.PixelPass = evaluation.GuessMatches(result.RowsPixel, truth.PixelSize[0], tolerance.Pixel) &&
+		evaluation.GuessMatches(result.ColsPixel, truth.PixelSize[1], tolerance.Pixel)
+	result.GridPass = evaluation.GuessMatches(result.RowsGrid, truth.GridSize[0], tolerance.Grid) &&
+		evaluation.GuessMatches(result.ColsGrid, truth.GridSize[1], tolerance.Grid)
+	result.SizePass = restored.Image.Rect.Dx() == truth.ArtSize[0] && restored.Image.Rect.Dy() == truth.ArtSize[1]
+
+	return result, nil
+}
@@ -0,0 +1,226 @@
+package benchmark
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+/*
+	Upper bounds of pixel size buckets used by failure envelope, last bucket holds all larger sizes
+*/
+var pixel_bucket_limits = []uint{2, 4, 8, 16, 32}
+
+/*
+	CurvePoint holds accuracy of all cases generated with one value of a swept degradation.
+	A case passes if both pixel size and gridline width were guessed within tolerance on both axes.
+	SizePassed counts cases whose restored image has exactly the size of the original sprite.
+*/
+type CurvePoint struct {
+	Value float64 `json:"value"`
+	Total int `json:"total"`
+	Passed int `json:"passed"`
+	PixelPassed int `json:"pixel_passed"`
+	GridPassed int `json:"grid_passed"`
+	SizePassed int `json:"size_passed"`
+	Errors int `json:"errors"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+type Curve struct {
+	Sweep string `json:"sweep"`
+	Points []CurvePoint `json:"points"`
+}
+
+/*
+	EnvelopeCell holds accuracy of all cases with pixel size in [PixelMin, PixelMax] and given gridline width,
+	accumulated over every point of every sweep. PixelMax 0 means the bucket has no upper limit.
+*/
+type EnvelopeCell struct {
+	PixelMin uint `json:"pixel_min"`
+	PixelMax uint `json:"pixel_max"`
+	GridSize uint `json:"grid_size"`
+	Total int `json:"total"`
+	Passed int `json:"passed"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+/*
+	Report holds every case result in the order they were run,
+	accuracy curves in the order of sweeps and envelope cells sorted by pixel bucket and gridline width.
+*/
+type Report struct {
+	Cases []CaseResult `json:"cases"`
+	Curves []Curve `json:"curves"`
+	Envelope []EnvelopeCell `json:"envelope"`
+}
+
+func buildReport(results []CaseResult) Report {
+	report := Report{Cases: results}
+
+	for _, result := range results {
+		curve_id := len(report.Curves) - 1
+		if curve_id < 0 || report.Curves[curve_id].Sweep != result.Sweep {
+			report.Curves = append(report.Curves, Curve{Sweep: result.Sweep})
+			curve_id += 1
+		}
+		curve := &report.Curves[curve_id]
+
+		point_id := len(curve.Points) - 1
+		if point_id < 0 || curve.Points[point_id].Value != result.Value {
+			curve.Points = append(curve.Points, CurvePoint{Value: result.Value})
+			point_id += 1
+		}
+		addToPoint(&curve.Points[point_id], result)
+	}
+
+	report.Envelope = buildEnvelope(results)
+	return report
+}
+
+func addToPoint(point *CurvePoint, result CaseResult) {
+	point.Total += 1
+	if result.PixelPass && result.GridPass {
+		point.Passed += 1
+	}
+	if result.PixelPass {
+		point.PixelPassed += 1
+	}
+	if result.GridPass {
+		point.GridPassed += 1
+	}
+	if result.SizePass {
+		point.SizePassed += 1
+	}
+	if result.Error != "" {
+		point.Errors += 1
+	}
+	point.Accuracy = float64(point.Passed) / float64(point.Total)
+}
+
+/*
+	Returns index of pixel size bucket that contains <pixel_size>
+*/
+func pixelBucketIndex(pixel_size uint) int {
+	for bucket_id, limit := range pixel_bucket_limits {
+		if pixel_size <= limit {
+			return bucket_id
+		}
+	}
+	return len(pixel_bucket_limits)
+}
+
+func buildEnvelope(results []CaseResult) []EnvelopeCell {
+	var max_grid uint = 0
+	for _, result := range results {
+		max_grid = max(max_grid, result.GridSize)
+	}
+	grid_count := int(max_grid) + 1
+
+	bucket_count := len(pixel_bucket_limits) + 1
+	cells := make([]EnvelopeCell, bucket_count * grid_count)
+	for bucket_id := 0; bucket_id < bucket_count; bucket_id++ {
+		var pixel_min, pixel_max uint = 1, 0
+		if bucket_id > 0 {
+			pixel_min = pixel_bucket_limits[bucket_id - 1] + 1
+		}
+		if bucket_id < len(pixel_bucket_limits) {
+			pixel_max = pixel_bucket_limits[bucket_id]
+		}
+		for grid := 0; grid < grid_count; grid++ {
+			cells[bucket_id * grid_count + grid] = EnvelopeCell{PixelMin: pixel_min, PixelMax: pixel_max, GridSize: uint(grid)}
+		}
+	}
+
+	for _, result := range results {
+		cell := &cells[pixelBucketIndex(result.PixelSize) * grid_count + int(result.GridSize)]
+		cell.Total += 1
+		if result.PixelPass && result.GridPass {
+			cell.Passed += 1
+		}
+		cell.Accuracy = float64(cell.Passed) / float64(cell.Total)
+	}
+
+	// drop combinations that were never generated
+	envelope := make([]EnvelopeCell, 0, len(cells))
+	for _, cell := range cells {
+		if cell.Total > 0 {
+			envelope = append(envelope, cell)
+		}
+	}
+	return envelope
+}
+
+/*
+	Prints accuracy curves of all sweeps followed by failure envelope table
+*/
+func PrintReport(writer io.Writer, report Report) {
+	for _, curve := range report.Curves {
+		fmt.Fprintf(writer, "%-14s %7s %7s %7s %7s %7s %7s %9s\n", curve.Sweep, "TOTAL", "PASSED", "PIXEL", "GRID", "SIZE", "ERRORS", "ACCURACY")
+		for _, point := range curve.Points {
+			fmt.Fprintf(writer, "%-14s %7d %7d %7d %7d %7d %7d %8.1f%%\n",
+				formatFloat(point.Value), point.Total, point.Passed,
+				point.PixelPassed, point.GridPassed, point.SizePassed, point.Errors,
+				point.Accuracy * 100.0,
+			)
+		}
+		fmt.Fprintln(writer)
+	}
+
+	fmt.Fprintf(writer, "%-14s %7s %7s %7s %9s\n", "PIXEL", "GRID", "TOTAL", "PASSED", "ACCURACY")
+	for _, cell := range report.Envelope {
+		pixel_range := fmt.Sprintf("%d-%d", cell.PixelMin, cell.PixelMax)
+		if cell.PixelMax == 0 {
+			pixel_range = fmt.Sprintf("%d+", cell.PixelMin)
+		}
+		fmt.Fprintf(writer, "%-14s %7d %7d %7d %8.1f%%\n", pixel_range, cell.GridSize, cell.Total, cell.Passed, cell.Accuracy * 100.0)
+	}
+}
+
+/*
+	Writes the whole report as indented JSON
+*/
+func WriteJSON(writer io.Writer, report Report) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+/*
+	Writes one CSV row per point of every accuracy curve, with a header row.
+*/
+func WriteCSV(writer io.Writer, report Report) error {
+	csv_writer := csv.NewWriter(writer)
+	header := []string{"sweep", "value", "total", "passed", "pixel_passed", "grid_passed", "size_passed", "errors", "accuracy"}
+	if err := csv_writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, curve := range report.Curves {
+		for _, point := range curve.Points {
+			record := []string{
+				curve.Sweep,
+				formatFloat(point.Value),
+				strconv.Itoa(point.Total),
+				strconv.Itoa(point.Passed),
+				strconv.Itoa(point.PixelPassed),
+				strconv.Itoa(point.GridPassed),
+				strconv.Itoa(point.SizePassed),
+				strconv.Itoa(point.Errors),
+				formatFloat(point.Accuracy),
+			}
+			if err := csv_writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	csv_writer.Flush()
+	return csv_writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,49 @@
+package benchmark
+
+import (
+	"image"
+	"math/rand"
+)
+
+/*
+	GenerateSprite makes a random low resolution sprite resembling pixel art:
+	a background filled with one palette colour, random rectangles of other palette colours
+	and a small fraction of single pixels with random palette colours (dithering-like detail).
+	All pixels are opaque. The same rng state always produces the same sprite.
+*/
+func GenerateSprite(rng *rand.Rand, width, height int, palette_size int) *image.RGBA {
+	palette_size = max(palette_size, 2)
+	palette := make([][4]uint8, palette_size)
+	for i := range palette {
+		palette[i] = [4]uint8{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	}
+
+	sprite := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(sprite, sprite.Rect, palette[0])
+
+	rectangle_count := max(width * height / 12, 1)
+	for i := 0; i < rectangle_count; i++ {
+		rect_width := 1 + rng.Intn(max(width / 3, 1))
+		rect_height := 1 + rng.Intn(max(height / 3, 1))
+		x, y := rng.Intn(width), rng.Intn(height)
+		rect := image.Rect(x, y, x + rect_width, y + rect_height).Intersect(sprite.Rect)
+		fillRect(sprite, rect, palette[1 + rng.Intn(palette_size - 1)])
+	}
+
+	detail_count := width * height / 20
+	for i := 0; i < detail_count; i++ {
+		x, y := rng.Intn(width), rng.Intn(height)
+		fillRect(sprite, image.Rect(x, y, x + 1, y + 1), palette[rng.Intn(palette_size)])
+	}
+
+	return sprite
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, color [4]uint8) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			flat_id := img.PixOffset(x, y)
+			copy(img.Pix[flat_id: flat_id + 4], color[:])
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+import (
+	"pixel_restoration/benchmark"
+	"pixel_restoration/restore"
+)
+
+func commandBenchmark(args []string) int {
+	flags := flag.NewFlagSet("benchmark", flag.ContinueOnError)
+	options := restore.GetBaseOptions()
+	apply_pipeline_flags := addPipelineFlags(flags, &options)
+	config := benchmark.GetBaseConfig()
+	flags.Int64Var(&config.Seed, "seed", config.Seed, "seed of generated images, the same seed always generates the same benchmark")
+	flags.IntVar(&config.CasesPerPoint, "cases", config.CasesPerPoint, "number of random images generated for every point of every sweep")
+	flags.UintVar(&config.MinPixel, "min-pixel", config.MinPixel, "smallest generated pixel size")
+	flags.UintVar(&config.MaxPixel, "max-pixel", config.MaxPixel, "largest generated pixel size")
+	flags.UintVar(&config.MaxGrid, "max-grid", config.MaxGrid, "largest generated gridline width")
+	flags.IntVar(&config.MaxImageSize, "max-size", config.MaxImageSize, "upper limit on width and height of generated images before the scale sweep, larger pixel sizes are reduced to fit 8 art pixels")
+	flags.Float64Var(&config.Tolerance.Pixel, "pixel-tolerance", config.Tolerance.Pixel, "allowed difference of guessed pixel size")
+	flags.Float64Var(&config.Tolerance.Grid, "grid-tolerance", config.Tolerance.Grid, "allowed difference of guessed grid width")
+	flags.StringVar(&config.SaveDir, "save-dir", config.SaveDir, "save every generated image to this directory")
+	json_path := flags.String("json", "", "write machine readable JSON report with every case to this path")
+	csv_path := flags.String("csv", "", "write accuracy curves as CSV to this path")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pixel_restoration benchmark [flags]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return EXIT_OK
+		}
+		return EXIT_USAGE
+	}
+	if err := apply_pipeline_flags(); err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark: %v\n", err)
+		return EXIT_USAGE
+	}
+	if config.CasesPerPoint < 1 || config.MinPixel < 1 || config.MaxPixel < config.MinPixel {
+		fmt.Fprintln(os.Stderr, "benchmark: -cases and -min-pixel must be positive and -max-pixel not smaller than -min-pixel")
+		return EXIT_USAGE
+	}
+	if config.MaxImageSize < 8 {
+		fmt.Fprintf(os.Stderr, "benchmark: -max-size must be at least 8, got %d\n", config.MaxImageSize)
+		return EXIT_USAGE
+	}
+
+	if config.SaveDir != "" {
+		if err := os.MkdirAll(config.SaveDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "benchmark: cannot create directory: %v\n", err)
+			return EXIT_IO_ERROR
+		}
+	}
+
+	report, err := benchmark.Run(config, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	benchmark.PrintReport(os.Stdout, report)
+
+	if err := writeReportFile(*json_path, report, benchmark.WriteJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark: cannot write JSON report: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	if err := writeReportFile(*csv_path, report, benchmark.WriteCSV); err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark: cannot write CSV report: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	return EXIT_OK
+}
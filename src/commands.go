@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+import (
+	"pixel_restoration/contrast"
+	"pixel_restoration/gridlines"
+	"pixel_restoration/images"
+	"pixel_restoration/restore"
+	"pixel_restoration/sampling"
+)
+
+/*
+	Registers flags shared by all commands that run the restoration pipeline.
+	Values are written directly to provided options struct once flag set is parsed.
+	Returned function must be called after parsing, it applies flags that need conversion
+	and checks the options with restore.ValidateOptions, including flags registered by the command itself.
+*/
+func addPipelineFlags(flags *flag.FlagSet, options *restore.Options) func() error {
+	flags.IntVar(&options.KuwaharaRadius, "kuwahara-radius", options.KuwaharaRadius,
+		"radius of Kuwahara preprocessing filter, 0 disables the filter")
+	kuwahara_sigma := flags.Float64("kuwahara-sigma", float64(options.KuwaharaSigma),
+		"sigma of Kuwahara gaussian kernel, 0 computes it from radius")
+	color_metric := flags.String("color-metric", contrast.MetricName(options.ColorMetric),
+		"difference of adjacent pixels: rgb, cie76, ciede2000, oklab, luminance or max-channel (perceptual metrics help pastel and low-contrast palettes)")
+	flags.Float64Var(&options.PeakHeight.MinPeakHeightLimit, "peak-height-limit", options.PeakHeight.MinPeakHeightLimit,
+		"upper limit of edge detection threshold")
+	threshold_mode := flags.String("threshold", contrast.ThresholdName(options.PeakHeight.Mode),
+		"edge threshold: global (one for the image), line (per row/column), otsu (distance histogram) or tile (per square tile), adaptive ones only lower the global one")
+	flags.IntVar(&options.PeakHeight.TileSize, "threshold-tile", options.PeakHeight.TileSize,
+		"side of tiles thresholded separately by -threshold tile")
+	flags.IntVar(&options.Cleanup.MinRunLength, "cleanup-min-run", options.Cleanup.MinRunLength,
+		"remove runs of edge pixels along gridlines shorter than this (against-the-grain edges, noise)")
+	flags.IntVar(&options.Cleanup.MaxGapLength, "cleanup-max-gap", options.Cleanup.MaxGapLength,
+		"bridge gaps of at most this many pixels in gridline runs, 0 disables bridging")
+	flags.IntVar(&options.Cleanup.MinClusterHeight, "cleanup-min-cluster", options.Cleanup.MinClusterHeight,
+		"remove clusters of edge pixels spanning fewer rows than this (text, watermarks), 0 disables it")
+	flags.BoolVar(&options.Peaks.Enabled, "peaks", options.Peaks.Enabled,
+		"keep only peaks of edge distances, so edges smeared over 2-3 pixels (anti-aliasing, JPEG) count once")
+	flags.Float64Var(&options.Peaks.MinProminence, "peak-prominence", options.Peaks.MinProminence,
+		"minimum prominence of an edge peak in distance units (0 - 255), used by -peaks")
+	flags.IntVar(&options.Peaks.MinDistance, "peak-distance", options.Peaks.MinDistance,
+		"minimum distance between edge peaks in pixels, 1 pixel wide gridlines excepted, used by -peaks")
+	sampling_mode := flags.String("sampling", sampling.SamplingModeName(options.Sampling.Mode), "cell colour sampling mode: median or mode")
+	count_mode := flags.String("count-mode", contrast.CountModeName(options.MostFrequent.CountMode),
+		"how edge pixels at one position are counted: pixels, longest-run or squared-runs (runs score continuous gridlines above fragments)")
+	estimator := flags.String("estimator", restore.EstimatorName(options.Estimator.Mode),
+		"pixel size estimator: histogram, spectral (autocorrelation/DFT of edge counts) or cross-check (histogram verified by spectral)")
+	joint_mode := flags.String("joint", gridlines.JointModeName(options.Joint.Mode),
+		"how guesses of both axes are coupled: independent, fixed-aspect (one pixel size for both axes, scaled by -aspect) or shared-grid (only gridline width is shared, for stretched sources)")
+	flags.Float64Var(&options.Joint.AspectRatio, "aspect", options.Joint.AspectRatio,
+		"expected pixel height divided by pixel width, used by -joint fixed-aspect")
+	flags.BoolVar(&options.DetectMajorGrid, "major-grid", options.DetectMajorGrid,
+		"detect thicker gridlines repeating every few cells (double / major-minor grids) and treat them as grid")
+	flags.BoolVar(&options.DetectCellStyle, "cell-style", options.DetectCellStyle,
+		"recognise shading repeated in every cell (beveled tiles, beads, bricks) and sample cell colours without it")
+	flags.BoolVar(&options.SampleLattice, "lattice", options.SampleLattice,
+		"sample restored image using fitted sub-pixel lattices, for non-integer pixel sizes like 10.5")
+	flags.BoolVar(&options.Deskew.Enabled, "deskew", options.Deskew.Enabled,
+		"detect grids rotated by up to a few degrees and straighten the image before detection")
+	flags.BoolVar(&options.Crop.Enabled, "crop", options.Crop.Enabled,
+		"detect uniform margins, frames and non-periodic borders around the art and detect the grid only inside the art")
+	flags.BoolVar(&options.Fallback.Enabled, "fallback", options.Fallback.Enabled,
+		"re-run variants of the pipeline on edge cases (tiny pixels, large unknown sections, mismatched axes), -fallback=false runs only the base pipeline")
+
+	return func() error {
+		options.KuwaharaSigma = float32(*kuwahara_sigma)
+		sample_mode, ok := sampling.SamplingModeFromName(*sampling_mode)
+		if !ok {
+			return fmt.Errorf("unknown sampling mode %q", *sampling_mode)
+		}
+		options.Sampling.Mode = sample_mode
+		metric, ok := contrast.MetricFromName(*color_metric)
+		if !ok {
+			return fmt.Errorf("unknown color metric %q", *color_metric)
+		}
+		options.ColorMetric = metric
+		threshold, ok := contrast.ThresholdFromName(*threshold_mode)
+		if !ok {
+			return fmt.Errorf("unknown threshold mode %q", *threshold_mode)
+		}
+		options.PeakHeight.Mode = threshold
+		edge_count_mode, ok := contrast.CountModeFromName(*count_mode)
+		if !ok {
+			return fmt.Errorf("unknown count mode %q", *count_mode)
+		}
+		options.MostFrequent.CountMode = edge_count_mode
+		estimator_mode, ok := restore.EstimatorFromName(*estimator)
+		if !ok {
+			return fmt.Errorf("unknown estimator %q", *estimator)
+		}
+		options.Estimator.Mode = estimator_mode
+		joint, ok := gridlines.JointModeFromName(*joint_mode)
+		if !ok {
+			return fmt.Errorf("unknown joint mode %q", *joint_mode)
+		}
+		options.Joint.Mode = joint
+		return restore.ValidateOptions(*options)
+	}
+}
+
+/*
+	Parses flags and makes sure exactly one positional argument (input image path) is left.
+	Returns input path and exit code, exit code other than EXIT_OK means the command should stop.
+*/
+func parseCommandFlags(flags *flag.FlagSet, args []string) (string, int) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return "", EXIT_OK
+		}
+		return "", EXIT_USAGE
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%s: expected exactly one input image, got %d arguments\n", flags.Name(), flags.NArg())
+		flags.Usage()
+		return "", EXIT_USAGE
+	}
+	return flags.Arg(0), EXIT_OK
+}
+
+/*
+	Loads input image and runs restoration pipeline on it, reporting any errors to standard error.
+	Failure to write debug output is reported as EXIT_IO_ERROR, invalid options as EXIT_USAGE
+	and every other pipeline error as EXIT_DETECTION_FAILURE.
+*/
+func loadAndRestore(command string, input_path string, options restore.Options) (restore.Result, int) {
+	img, err := images.RGBALoadFromFile(input_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: cannot load input image: %v\n", command, err)
+		return restore.Result{}, EXIT_IO_ERROR
+	}
+
+	result, err := restore.Restore(img, options)
+	if errors.Is(err, restore.ErrDebugOutput) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		return result, EXIT_IO_ERROR
+	}
+	if errors.Is(err, restore.ErrInvalidFilterParams) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		return restore.Result{}, EXIT_USAGE
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: detection failed: %v\n", command, err)
+		return restore.Result{}, EXIT_DETECTION_FAILURE
+	}
+	return result, EXIT_OK
+}
+
+/*
+	Registers -min-confidence flag, commands using it exit with EXIT_LOW_CONFIDENCE if result is less confident
+*/
+func addMinConfidenceFlag(flags *flag.FlagSet) *float64 {
+	return flags.Float64("min-confidence", 0.0,
+		"exit with code 4 if confidence of the result (0.0 - 1.0) is lower than this value, the output is still written")
+}
+
+func checkConfidence(command string, result restore.Result, min_confidence float64) int {
+	if result.Confidence.Score < min_confidence {
+		fmt.Fprintf(os.Stderr, "%s: confidence %.3f is lower than %.3f\n", command, result.Confidence.Score, min_confidence)
+		return EXIT_LOW_CONFIDENCE
+	}
+	return EXIT_OK
+}
+
+func commandDetect(args []string) int {
+	flags := flag.NewFlagSet("detect", flag.ContinueOnError)
+	options := restore.GetBaseOptions()
+	apply_pipeline_flags := addPipelineFlags(flags, &options)
+	min_confidence := addMinConfidenceFlag(flags)
+
+	input_path, code := parseCommandFlags(flags, args)
+	if code != EXIT_OK || input_path == "" {
+		return code
+	}
+	if err := apply_pipeline_flags(); err != nil {
+		fmt.Fprintf(os.Stderr, "detect: %v\n", err)
+		return EXIT_USAGE
+	}
+
+	result, code := loadAndRestore("detect", input_path, options)
+	if code != EXIT_OK {
+		return code
+	}
+
+	printAxisDetection("X axis (rows)", result.Rows, options.Sampling)
+	printAxisDetection("Y axis (cols)", result.Cols, options.Sampling)
+	fmt.Printf("Restored size (width, height): %d %d\n", result.Image.Rect.Dx(), result.Image.Rect.Dy())
+	fmt.Printf("Strategy: %s\n", restore.StrategyName(result.Strategy))
+	if result.Rotation != 0 {
+		fmt.Printf("Rotation: %.3f degrees\n", result.Rotation)
+	}
+	if options.Crop.Enabled {
+		fmt.Printf("Art bounds: %v\n", result.ArtBounds)
+	}
+	printConfidence(result.Confidence)
+	return checkConfidence("detect", result, *min_confidence)
+}
+
+/*
+	Prints overall confidence followed by diagnostics of both axes
+*/
+func printConfidence(confidence restore.Confidence) {
+	fmt.Printf("Confidence: %.3f (axis agreement %.3f)\n", confidence.Score, confidence.AxisAgreement)
+	axes := [2]restore.AxisConfidence{confidence.Rows, confidence.Cols}
+	for i, name := range [2]string{"X axis (rows)", "Y axis (cols)"} {
+		axis := axes[i]
+		fmt.Printf("    %s: %.3f | runlength coverage %.3f, score margin %.3f, unknown %.3f, cleanup %d px (%.3f)\n",
+			name, axis.Score, axis.RunlengthCoverage, axis.ScoreMargin, axis.UnknownFraction,
+			axis.CleanupChanged, axis.CleanupFraction,
+		)
+		fmt.Printf("        cleanup: %d px in short runs, %d px in clusters, %d px bridged\n",
+			axis.Cleanup.ShortRuns, axis.Cleanup.Clusters, axis.Cleanup.BridgedGaps,
+		)
+		if axis.PixelSizeFactor < 1.0 {
+			fmt.Printf("        pixel size of at most 2 px, score multiplied by %.3f\n", axis.PixelSizeFactor)
+		}
+	}
+}
+
+/*
+	Prints guessed sizes of a single axis.
+	Offset is the position of the first pixel cell that is sampled into the restored image.
+*/
+func printAxisDetection(name string, axis restore.AxisResult, params sampling.SamplingParams) {
+	cells := sampling.GetPixelCellRanges(axis.Fixed, params.EdgeCellMinFraction)
+	offset := 0
+	if len(cells) > 0 {
+		offset = cells[0][0]
+	}
+
+	fmt.Printf("%s:\n", name)
+	fmt.Printf("    Pixel size: %.3f %v\n", axis.PixelGuess.Mean, axis.PixelGuess.Bounds)
+	fmt.Printf("    Grid size: %.3f %v\n", axis.GridGuess.Mean, axis.GridGuess.Bounds)
+	fmt.Printf("    Offset: %d\n", offset)
+	fmt.Printf("    Cells: %d\n", len(cells))
+	fmt.Printf("    Lattice: period %.3f, phase %.3f, grid %.3f (coherence %.3f)\n",
+		axis.Lattice.Period, axis.Lattice.Phase, axis.Lattice.GridWidth, axis.Lattice.Coherence,
+	)
+	if axis.Spectral.Period > 0 {
+		fmt.Printf("    Spectral period: %.3f (strength %.3f, coherence %.3f), used estimator: %s\n",
+			axis.Spectral.Period, axis.Spectral.Strength, axis.Spectral.Coherence, restore.EstimatorName(axis.Estimator),
+		)
+	}
+	if axis.Major.Period > 0 {
+		fmt.Printf("    Major grid: every %d cells, size %.3f %v (%d lines, regularity %.3f)\n",
+			axis.Major.Period, axis.Major.Mean, axis.Major.Bounds, axis.Major.Count, axis.Major.Regularity,
+		)
+	}
+	if axis.CellStyle.Style == gridlines.CELL_SHADED {
+		fmt.Printf("    Shaded cells: period %.3f, shading band %.3f wide starting at %.3f (%.1f edges per band)\n",
+			axis.CellStyle.Period, axis.CellStyle.BandWidth, axis.CellStyle.BandStart, axis.CellStyle.ShadingEdges,
+		)
+	}
+	if axis.JointCorrected {
+		fmt.Printf("    Guess corrected by joint estimation\n")
+	}
+}
+
+func commandRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	options := restore.GetBaseOptions()
+	apply_pipeline_flags := addPipelineFlags(flags, &options)
+	output_path := flags.String("o", "restored.png", "path of output PNG image")
+	min_confidence := addMinConfidenceFlag(flags)
+
+	input_path, code := parseCommandFlags(flags, args)
+	if code != EXIT_OK || input_path == "" {
+		return code
+	}
+	if err := apply_pipeline_flags(); err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return EXIT_USAGE
+	}
+
+	result, code := loadAndRestore("restore", input_path, options)
+	if code != EXIT_OK {
+		return code
+	}
+
+	if err := images.RGBASaveToFile(*output_path, result.Image); err != nil {
+		fmt.Fprintf(os.Stderr, "restore: cannot save output image: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	return checkConfidence("restore", result, *min_confidence)
+}
+
+func commandUpscale(args []string) int {
+	flags := flag.NewFlagSet("upscale", flag.ContinueOnError)
+	pixel_size := flags.Uint("pixel", 8, "size of upscaled pixel block, must be larger than 0")
+	grid_size := flags.Uint("grid", 0, "width of gridlines, 0 draws no gridlines")
+	grid_color_hex := flags.String("grid-color", "000000ff", "gridline colour as RRGGBBAA hex string")
+	output_path := flags.String("o", "upscaled.png", "path of output PNG image")
+
+	input_path, code := parseCommandFlags(flags, args)
+	if code != EXIT_OK || input_path == "" {
+		return code
+	}
+
+	if *pixel_size == 0 {
+		fmt.Fprintln(os.Stderr, "upscale: -pixel must be larger than 0")
+		return EXIT_USAGE
+	}
+	grid_color, err := parseHexColor(*grid_color_hex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "upscale: %v\n", err)
+		return EXIT_USAGE
+	}
+
+	img, err := images.RGBALoadFromFile(input_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "upscale: cannot load input image: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	upscaled, err := images.AdvancedUpscaleGetNewImage(img, *pixel_size, *grid_size, grid_color)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "upscale: %v\n", err)
+		return EXIT_USAGE
+	}
+	if err := images.RGBASaveToFile(*output_path, upscaled); err != nil {
+		fmt.Fprintf(os.Stderr, "upscale: cannot save output image: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	return EXIT_OK
+}
+
+/*
+	Parses RRGGBBAA or RRGGBB hex string (alpha defaults to 255) into RGBA array.
+*/
+func parseHexColor(value string) ([4]uint8, error) {
+	color := [4]uint8{0, 0, 0, 255}
+	decoded, err := hex.DecodeString(value)
+	if err != nil || (len(decoded) != 3 && len(decoded) != 4) {
+		return color, fmt.Errorf("invalid colour %q, expected RRGGBB or RRGGBBAA hex string", value)
+	}
+	copy(color[:], decoded)
+	return color, nil
+}
+
+func commandDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	options := restore.GetBaseOptions()
+	apply_pipeline_flags := addPipelineFlags(flags, &options)
+	flags.StringVar(&options.DebugDir, "dir", DEBUG_DIR_PATH, "directory for debug images, created if missing")
+
+	input_path, code := parseCommandFlags(flags, args)
+	if code != EXIT_OK || input_path == "" {
+		return code
+	}
+	if err := apply_pipeline_flags(); err != nil {
+		fmt.Fprintf(os.Stderr, "debug: %v\n", err)
+		return EXIT_USAGE
+	}
+
+	if err := os.MkdirAll(options.DebugDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "debug: cannot create debug directory: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	start := time.Now()
+	_, code = loadAndRestore("debug", input_path, options)
+	fmt.Println(time.Since(start))
+	return code
+}
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"image"
+)
+
+/*
+	Returns colour of pixel relative to img.Rect.Min, fully transparent pixels are returned as {0, 0, 0, 0}
+*/
+func PixelColor(img *image.RGBA, x, y int) [4]uint8 {
+	flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+	if img.Pix[flat_id + 3] == 0 {
+		return [4]uint8{0, 0, 0, 0}
+	}
+	return [4]uint8{img.Pix[flat_id], img.Pix[flat_id + 1], img.Pix[flat_id + 2], img.Pix[flat_id + 3]}
+}
+
+/*
+	Returns true if no channel (alpha included) of the colours differs by more than tolerance
+*/
+func ColorsClose(a, b [4]uint8, tolerance uint8) bool {
+	for channel := 0; channel < 4; channel++ {
+		difference := int(a[channel]) - int(b[channel])
+		if difference > int(tolerance) || -difference > int(tolerance) {
+			return false
+		}
+	}
+	return true
+}
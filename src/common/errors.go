@@ -0,0 +1,36 @@
+package common
+
+import "errors"
+
+/*
+	Errors shared by all packages of the pipeline.
+	Functions wrap them with details using fmt.Errorf and %w, so callers should compare them with errors.Is.
+	Package restore re-exports them for users of the library.
+
+	ErrTooFewEdges:
+		Not enough edges were detected on an axis to guess pixel and gridline sizes
+	ErrAxisMismatch:
+		Sizes along an axis don't match, for example combined list is longer than the image or images have different sizes
+	ErrInvalidUpscaleParams:
+		Invalid arguments of upscaling, for example pixel size 0 or destination image of wrong size
+	ErrImageTooSmall:
+		Image is empty or too small to be processed
+	ErrIndexOutOfRange:
+		Row or column index lies outside of the image
+	ErrInvalidFilterParams:
+		Invalid arguments of an image filter, for example Kuwahara radius lower than 1
+	ErrInvalidLattice:
+		Detected sub-pixel lattice cannot describe a grid, for example period lower than 1 or gridline wider than the period
+	ErrInternal:
+		Unexpected failure of the pipeline (recovered panic), the input is most likely unusual rather than invalid
+*/
+var (
+	ErrTooFewEdges = errors.New("not enough edges detected to guess the grid")
+	ErrAxisMismatch = errors.New("sizes along an axis do not match")
+	ErrInvalidUpscaleParams = errors.New("invalid upscale parameters")
+	ErrImageTooSmall = errors.New("image is too small")
+	ErrIndexOutOfRange = errors.New("index outside of the image")
+	ErrInvalidFilterParams = errors.New("invalid filter parameters")
+	ErrInvalidLattice = errors.New("invalid lattice")
+	ErrInternal = errors.New("internal error")
+)
@@ -18,6 +18,19 @@ func CountNonZeroU(slice []uint) int {
 	return result
 }
 
+// Returns count of all non zero values in provided slice
+func CountNonZeroU8(slice []uint8) int {
+	var result int = 0
+
+	for _, value := range slice{
+		if value != 0 {
+			result += 1
+		}
+	}
+
+	return result
+}
+
 
 // todo make median of slice and median of array generic on all number types and move to common-slices if necessary
 
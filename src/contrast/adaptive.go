@@ -0,0 +1,159 @@
+package contrast
+
+import (
+	"image"
+	"slices"
+)
+
+import (
+	"pixel_restoration/images"
+)
+
+/*
+	Defines a set of uint8 constants used to select how edge distances are thresholded, see ThresholdAdaptive
+	THRESHOLD_GLOBAL:
+		one min peak height for the whole map, derived from its largest distance (see CalculateMinPeakHeight)
+	THRESHOLD_LINE:
+		every row of the distance map (one row or column of the image) is scaled by its own largest distance,
+		so a watermark or thick outline raises the threshold only of lines crossing it
+	THRESHOLD_OTSU:
+		threshold splitting the histogram of non-zero distances into two classes with the largest between-class variance,
+		it depends on the whole distribution instead of its single largest value
+	THRESHOLD_TILE:
+		square tiles of the distance map are scaled by their own largest distance
+*/
+const (
+	THRESHOLD_GLOBAL uint8 = iota
+	THRESHOLD_LINE uint8 = iota
+	THRESHOLD_OTSU uint8 = iota
+	THRESHOLD_TILE uint8 = iota
+)
+
+var threshold_names = [4]string{"global", "line", "otsu", "tile"}
+
+/*
+	Returns short name of a THRESHOLD_ constant, used in reports and command line flags
+*/
+func ThresholdName(mode uint8) string {
+	if int(mode) >= len(threshold_names) {
+		return "unknown"
+	}
+	return threshold_names[mode]
+}
+
+/*
+	Returns THRESHOLD_ constant with given name and true, or false if no threshold mode has that name
+*/
+func ThresholdFromName(name string) (uint8, bool) {
+	for mode, mode_name := range threshold_names {
+		if mode_name == name {
+			return uint8(mode), true
+		}
+	}
+	return 0, false
+}
+
+/*
+	Thresholds distances the same way as ThresholdWithMinHeight, but with min peak height chosen by params.Mode.
+
+	min_peak_height is the global height (usually from CalculateMinPeakHeight, possibly lowered by the caller).
+	Adaptive modes only lower it where the contrast is locally weaker: line and tile heights are
+	min_peak_height * <local max distance> / <max distance of the map>, Otsu height is used where it is lower than min_peak_height.
+	All adaptive heights are kept within [params.MinLocalFraction * min_peak_height, min_peak_height].
+	THRESHOLD_GLOBAL gives exactly the result of ThresholdWithMinHeight.
+*/
+func ThresholdAdaptive(distances *image.Gray, min_peak_height uint8, params PeakHeightParams) *image.Gray {
+	if params.Mode == THRESHOLD_GLOBAL || int(params.Mode) >= len(threshold_names) {
+		return ThresholdWithMinHeight(distances, min_peak_height)
+	}
+
+	distances_new := images.GrayscaleGetNormalized(distances)
+	width, height := distances_new.Rect.Dx(), distances_new.Rect.Dy()
+	if width * height == 0 {
+		return distances_new
+	}
+	map_max := slices.Max(distances_new.Pix)
+	floor := max(params.MinLocalFraction * float64(min_peak_height), 1.0)
+	// scales local maximum to min peak height and keeps it within allowed range
+	local_height := func(local_max uint8) uint8 {
+		if map_max == 0 {
+			return min_peak_height
+		}
+		scaled := float64(min_peak_height) * float64(local_max) / float64(map_max)
+		return uint8(min(max(scaled, floor), float64(min_peak_height)) + 0.5)
+	}
+	apply := func(rect image.Rectangle, peak_height uint8) {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				flat_id := y * distances_new.Stride + x
+				if distances_new.Pix[flat_id] >= peak_height {
+					distances_new.Pix[flat_id] = 255
+				} else {
+					distances_new.Pix[flat_id] = 0
+				}
+			}
+		}
+	}
+
+	switch params.Mode {
+	case THRESHOLD_LINE:
+		for y := 0; y < height; y++ {
+			line := distances_new.Pix[y * distances_new.Stride: y * distances_new.Stride + width]
+			apply(image.Rect(0, y, width, y + 1), local_height(slices.Max(line)))
+		}
+	case THRESHOLD_OTSU:
+		otsu := float64(otsuThreshold(distances_new.Pix))
+		peak_height := uint8(min(max(otsu, floor), float64(min_peak_height)) + 0.5)
+		apply(distances_new.Rect, peak_height)
+	case THRESHOLD_TILE:
+		tile_size := max(params.TileSize, 1)
+		for tile_y := 0; tile_y < height; tile_y += tile_size {
+			for tile_x := 0; tile_x < width; tile_x += tile_size {
+				tile := image.Rect(tile_x, tile_y, min(tile_x + tile_size, width), min(tile_y + tile_size, height))
+				var tile_max uint8 = 0
+				for y := tile.Min.Y; y < tile.Max.Y; y++ {
+					tile_max = max(tile_max, slices.Max(distances_new.Pix[y * distances_new.Stride + tile.Min.X: y * distances_new.Stride + tile.Max.X]))
+				}
+				apply(tile, local_height(tile_max))
+			}
+		}
+	}
+	return distances_new
+}
+
+/*
+	Returns Otsu threshold of non-zero values: the lowest value of the upper class of the split
+	that maximizes between-class variance. Zero values (flat areas and padding) would dominate the histogram.
+	Returns 1 if there are less than two distinct non-zero values.
+*/
+func otsuThreshold(values []uint8) uint8 {
+	var histogram [256]float64
+	for _, value := range values {
+		if value > 0 {
+			histogram[value] += 1
+		}
+	}
+
+	var total, total_sum float64 = 0, 0
+	for value := 1; value < 256; value++ {
+		total += histogram[value]
+		total_sum += float64(value) * histogram[value]
+	}
+
+	var best_threshold uint8 = 1
+	var best_variance, lower_count, lower_sum float64 = 0, 0, 0
+	for value := 1; value < 255; value++ {
+		lower_count += histogram[value]
+		lower_sum += float64(value) * histogram[value]
+		upper_count := total - lower_count
+		if lower_count == 0 || upper_count == 0 {
+			continue
+		}
+		mean_difference := lower_sum / lower_count - (total_sum - lower_sum) / upper_count
+		variance := lower_count * upper_count * mean_difference * mean_difference
+		if variance > best_variance {
+			best_variance, best_threshold = variance, uint8(value + 1)
+		}
+	}
+	return best_threshold
+}
@@ -5,30 +5,173 @@ import "image"
 import "pixel_restoration/images"
 
 
+/*
+	MinRunLength: int
+		Runs of edge pixels along the gridline direction (columns of the map) shorter than this are removed,
+		they are edges running against the grain (horizontal art edges, text strokes, noise).
+		Runs touching the first or the last row are kept, they may continue outside of the image. 2 removes single pixels.
+	MaxGapLength: int
+		Gaps of at most this many pixels between two runs of the same column are filled, which reconnects gridlines
+		broken by noise, JPEG blocks or art colours close to the grid colour. 0 disables bridging.
+	MinClusterHeight: int
+		Connected clusters of edge pixels (8-connectivity) spanning fewer rows than this are removed,
+		isolated blobs of text, logos or watermark characters form such clusters, while gridlines span the art.
+		0 disables cluster removal, it also removes edges of lone art pixels shorter than this.
+
+	Constraints:
+		MinRunLength >= 0
+		MaxGapLength >= 0
+		MinClusterHeight >= 0
+*/
+type CleanupParams struct {
+	MinRunLength int
+	MaxGapLength int
+	MinClusterHeight int
+}
+
+func GetBaseCleanupParams() CleanupParams {
+	return CleanupParams{
+		MinRunLength: 2,
+		MaxGapLength: 0,
+		MinClusterHeight: 0,
+	}
+}
+
+/*
+	Number of pixels changed by each operation of CleanupEdgeArtifacts
+
+	BridgedGaps: int
+		Pixels added by filling gaps in runs
+	ShortRuns: int
+		Pixels removed as parts of short runs
+	Clusters: int
+		Pixels removed as parts of small clusters
+*/
+type CleanupCounts struct {
+	BridgedGaps int
+	ShortRuns int
+	Clusters int
+}
+
+/*
+	Returns number of edge pixels removed by cleanup (short runs and clusters), bridged pixels are not counted
+*/
+func CleanupRemoved(counts CleanupCounts) int {
+	return counts.ShortRuns + counts.Clusters
+}
+
 /* 
 	Given a binarized (tresholded with min peak height) gray image od edges,
-	Return a new image where random single pixels, diagonal and against-the-grain lines are removed.
-	Second return is a count of manipulated pixels. (High may sugest noisy and watermarkey image)
+	Return a new image where gaps in runs are bridged, short against-the-grain runs and small isolated clusters
+	are removed, in this order (see CleanupParams).
+	Second return is a count of manipulated pixels of each operation. (High may sugest noisy and watermarkey image)
 */
-func CleanupEdgeArtifacts(edges_binary *image.Gray) (*image.Gray, int){
+func CleanupEdgeArtifacts(edges_binary *image.Gray, params CleanupParams) (*image.Gray, CleanupCounts){
 	result := images.GrayscaleGetNormalized(edges_binary)
 	width, height := result.Rect.Dx(), result.Rect.Dy()
+	var counts CleanupCounts
+
+	// calls visit with [start, end) of every run of edge pixels in column x
+	column_runs := func(x int, visit func(start, end int)) {
+		for y := 0; y < height; {
+			if result.Pix[y * width + x] == 0 {
+				y += 1
+				continue
+			}
+			end := y
+			for end < height && result.Pix[end * width + x] != 0 {
+				end += 1
+			}
+			visit(y, end)
+			y = end
+		}
+	}
 
-	count_changed := 0
+	if params.MaxGapLength > 0 {
+		for x := 0; x < width; x++ {
+			previous_end := -1
+			column_runs(x, func(start, end int) {
+				if previous_end >= 0 && start - previous_end <= params.MaxGapLength {
+					for y := previous_end; y < start; y++ {
+						result.Pix[y * width + x] = 255
+					}
+					counts.BridgedGaps += start - previous_end
+				}
+				previous_end = end
+			})
+		}
+	}
+
+	if params.MinRunLength > 1 {
+		for x := 0; x < width; x++ {
+			column_runs(x, func(start, end int) {
+				if end - start >= params.MinRunLength || start == 0 || end == height {
+					return
+				}
+				for y := start; y < end; y++ {
+					result.Pix[y * width + x] = 0
+				}
+				counts.ShortRuns += end - start
+			})
+		}
+	}
+
+	if params.MinClusterHeight > 1 {
+		counts.Clusters = removeSmallClusters(result, params.MinClusterHeight)
+	}
 
-	for y:= 1; y<height - 1;y++{
-		for x:=0; x<width; x++{
-			above_id := (y - 1) * width + x
-			curr_id := y * width + x 
-			below_id := (y + 1) * width + x
+	return result, counts
+}
 
-			if(result.Pix[above_id] == 0 && result.Pix[below_id] == 0 && result.Pix[curr_id] != 0){
-				result.Pix[curr_id] = 0
-				count_changed += 1
+/*
+	Removes 8-connected clusters of non-zero pixels spanning fewer than min_height rows from a normalized image in place.
+	Returns number of removed pixels.
+*/
+func removeSmallClusters(img *image.Gray, min_height int) int {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	visited := make([]bool, width * height)
+	removed := 0
+
+	stack := make([]int, 0)
+	cluster := make([]int, 0)
+	for start := range img.Pix {
+		if img.Pix[start] == 0 || visited[start] {
+			continue
+		}
+
+		// flood fill of the cluster, tracking its vertical extent
+		cluster = cluster[:0]
+		stack = append(stack[:0], start)
+		visited[start] = true
+		min_y, max_y := start / width, start / width
+		for len(stack) > 0 {
+			current := stack[len(stack) - 1]
+			stack = stack[:len(stack) - 1]
+			cluster = append(cluster, current)
+			x, y := current % width, current / width
+			min_y, max_y = min(min_y, y), max(max_y, y)
+
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x + dx, y + dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					neighbour := ny * width + nx
+					if img.Pix[neighbour] != 0 && !visited[neighbour] {
+						visited[neighbour] = true
+						stack = append(stack, neighbour)
+					}
+				}
 			}
+		}
 
+		if max_y - min_y + 1 < min_height {
+			for _, id := range cluster {
+				img.Pix[id] = 0
+			}
+			removed += len(cluster)
 		}
 	}
-
-	return result, count_changed
-}	
\ No newline at end of file
+	return removed
+}
@@ -0,0 +1,278 @@
+package contrast
+
+import (
+	"image"
+	"math"
+)
+
+import (
+	"pixel_restoration/common"
+)
+
+/*
+	Defines a set of uint8 constants used to select how the difference of two adjacent pixels is measured,
+	see CalculatePixelEdgeDistancesWithMetric
+	METRIC_RGB:
+		euclidean distance of sRGB values, over-weights blue and under-weights changes between dark hues
+	METRIC_CIE76:
+		euclidean distance in CIE L*a*b* (D65)
+	METRIC_CIEDE2000:
+		CIEDE2000 colour difference in CIE L*a*b*, the most uniform one, but several times slower than the others
+	METRIC_OKLAB:
+		euclidean distance in OKLab, nearly as uniform as CIEDE2000 for a fraction of its cost
+	METRIC_LUMINANCE:
+		difference of Rec. 709 luma, ignores hue changes of the same brightness
+	METRIC_MAX_CHANNEL:
+		largest difference of a single sRGB channel, a change of any channel counts fully
+*/
+const (
+	METRIC_RGB uint8 = iota
+	METRIC_CIE76 uint8 = iota
+	METRIC_CIEDE2000 uint8 = iota
+	METRIC_OKLAB uint8 = iota
+	METRIC_LUMINANCE uint8 = iota
+	METRIC_MAX_CHANNEL uint8 = iota
+)
+
+var metric_names = [6]string{"rgb", "cie76", "ciede2000", "oklab", "luminance", "max-channel"}
+
+/*
+	Returns short name of a METRIC_ constant, used in reports and command line flags
+*/
+func MetricName(metric uint8) string {
+	if int(metric) >= len(metric_names) {
+		return "unknown"
+	}
+	return metric_names[metric]
+}
+
+/*
+	Returns true if metric is one of METRIC_ constants
+*/
+func MetricIsKnown(metric uint8) bool {
+	return int(metric) < len(metric_names)
+}
+
+/*
+	Returns METRIC_ constant with given name and true, or false if no metric has that name
+*/
+func MetricFromName(name string) (uint8, bool) {
+	for metric, metric_name := range metric_names {
+		if metric_name == name {
+			return uint8(metric), true
+		}
+	}
+	return 0, false
+}
+
+/*
+	Largest distance of two sRGB colours for each METRIC_ constant, distances are divided by it before mapping to 0 - 255
+*/
+var metric_max_distances = [6]float64{441.674, 258.683, 119.474, 1.0, 255.0, 255.0}
+
+/*
+	Same as CalculatePixelEdgeDistances, but the difference of adjacent pixels is measured by one of METRIC_ constants.
+	Every metric is scaled so that the largest possible difference of two sRGB colours maps to 255.
+	METRIC_RGB gives exactly the result of CalculatePixelEdgeDistances. Colours are composited over black
+	(image.RGBA is alpha-premultiplied) and alpha difference is added to the distance, difference of 255 alone
+	maps to 255 just like in CalculatePixelEdgeDistances. Unknown metrics fall back to METRIC_RGB.
+*/
+func CalculatePixelEdgeDistancesWithMetric(img *image.RGBA, vertical bool, metric uint8) *image.Gray {
+	if metric == METRIC_RGB || !MetricIsKnown(metric) {
+		return CalculatePixelEdgeDistances(img, vertical)
+	}
+
+	var is_vertical int = common.Ternary(vertical, 1, 0)
+	sizes := [2]int{img.Rect.Dx(), img.Rect.Dy()}
+	height, width := sizes[1 - is_vertical], sizes[is_vertical]
+	result := image.NewGray(image.Rect(0, 0, width, height))
+
+	// colours are converted once per pixel, not once per pair
+	converted := convertColors(img, metric)
+	for outer := 0; outer < height; outer++ {
+		for inner := 0; inner < width - 1; inner++ {
+			curr := [2]int{inner, outer}
+			next := [2]int{inner + 1, outer}
+			curr_id := curr[1 - is_vertical] * sizes[0] + curr[is_vertical]
+			next_id := next[1 - is_vertical] * sizes[0] + next[is_vertical]
+
+			dist := scaledColorDistance(converted[curr_id], converted[next_id], metric)
+			result.Pix[outer * result.Stride + inner + 1] = uint8(min(dist + 0.5, 255.0))
+		}
+	}
+	return result
+}
+
+/*
+	Returns distance of two pixels converted by convertColors with alpha difference included,
+	scaled so that the largest difference of two sRGB colours (or of alpha alone) is 255
+*/
+func scaledColorDistance(a, b [4]float64, metric uint8) float64 {
+	// convertColors and colorDistance treat unknown metrics as METRIC_RGB too
+	if !MetricIsKnown(metric) {
+		metric = METRIC_RGB
+	}
+	max_distance := metric_max_distances[metric]
+	dist := colorDistance(a, b, metric)
+	alpha_dist := (a[3] - b[3]) * max_distance
+	return 255.0 * math.Sqrt(dist * dist + alpha_dist * alpha_dist) / max_distance
+}
+
+/*
+	Returns colours of all pixels (row by row, relative to img.Rect.Min) in the space the metric works in:
+	L*a*b* for CIE metrics, OKLab, luma in the first component for METRIC_LUMINANCE and sRGB otherwise.
+	Last component holds alpha as a fraction of 255.
+*/
+func convertColors(img *image.RGBA, metric uint8) [][4]float64 {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	converted := make([][4]float64, width * height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+			premultiplied := premultipliedColor(img.Pix, flat_id)
+			rgb := [3]uint8{uint8(premultiplied[0]), uint8(premultiplied[1]), uint8(premultiplied[2])}
+			var color [3]float64
+
+			switch metric {
+			case METRIC_CIE76, METRIC_CIEDE2000:
+				color = rgbToLab(rgb)
+			case METRIC_OKLAB:
+				color = rgbToOklab(rgb)
+			case METRIC_LUMINANCE:
+				color = [3]float64{0.2126 * premultiplied[0] + 0.7152 * premultiplied[1] + 0.0722 * premultiplied[2], 0, 0}
+			default:
+				color = [3]float64{premultiplied[0], premultiplied[1], premultiplied[2]}
+			}
+			converted[y * width + x] = [4]float64{color[0], color[1], color[2], premultiplied[3] / 255.0}
+		}
+	}
+	return converted
+}
+
+/*
+	Returns distance of colours (alpha is ignored) of two pixels converted by convertColors for the same metric
+*/
+func colorDistance(a, b [4]float64, metric uint8) float64 {
+	switch metric {
+	case METRIC_CIEDE2000:
+		return ciede2000([3]float64{a[0], a[1], a[2]}, [3]float64{b[0], b[1], b[2]})
+	case METRIC_LUMINANCE:
+		return math.Abs(a[0] - b[0])
+	case METRIC_MAX_CHANNEL:
+		return max(math.Abs(a[0] - b[0]), math.Abs(a[1] - b[1]), math.Abs(a[2] - b[2]))
+	default:
+		return math.Sqrt((a[0] - b[0]) * (a[0] - b[0]) + (a[1] - b[1]) * (a[1] - b[1]) + (a[2] - b[2]) * (a[2] - b[2]))
+	}
+}
+
+/*
+	Returns linear value (0.0 - 1.0) of an sRGB encoded channel
+*/
+func srgbToLinear(value uint8) float64 {
+	channel := float64(value) / 255.0
+	if channel <= 0.04045 {
+		return channel / 12.92
+	}
+	return math.Pow((channel + 0.055) / 1.055, 2.4)
+}
+
+/*
+	Converts sRGB colour to CIE L*a*b* with D65 white point
+*/
+func rgbToLab(rgb [3]uint8) [3]float64 {
+	r, g, b := srgbToLinear(rgb[0]), srgbToLinear(rgb[1]), srgbToLinear(rgb[2])
+	x := (0.4124564 * r + 0.3575761 * g + 0.1804375 * b) / 0.95047
+	y := 0.2126729 * r + 0.7151522 * g + 0.0721750 * b
+	z := (0.0193339 * r + 0.1191920 * g + 0.9503041 * b) / 1.08883
+
+	f := func(t float64) float64 {
+		const delta float64 = 6.0 / 29.0
+		if t > delta * delta * delta {
+			return math.Cbrt(t)
+		}
+		return t / (3.0 * delta * delta) + 4.0 / 29.0
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116.0 * fy - 16.0, 500.0 * (fx - fy), 200.0 * (fy - fz)}
+}
+
+/*
+	Converts sRGB colour to OKLab
+*/
+func rgbToOklab(rgb [3]uint8) [3]float64 {
+	r, g, b := srgbToLinear(rgb[0]), srgbToLinear(rgb[1]), srgbToLinear(rgb[2])
+	l := math.Cbrt(0.4122214708 * r + 0.5363325363 * g + 0.0514459929 * b)
+	m := math.Cbrt(0.2119034982 * r + 0.6806995451 * g + 0.1073969566 * b)
+	s := math.Cbrt(0.0883024619 * r + 0.2817188376 * g + 0.6299787005 * b)
+	return [3]float64{
+		0.2104542553 * l + 0.7936177850 * m - 0.0040720468 * s,
+		1.9779984951 * l - 2.4285922050 * m + 0.4505937099 * s,
+		0.0259040371 * l + 0.7827717662 * m - 0.8086757660 * s,
+	}
+}
+
+/*
+	Returns CIEDE2000 colour difference of two L*a*b* colours with unit weighting factors
+*/
+func ciede2000(lab1, lab2 [3]float64) float64 {
+	const deg float64 = math.Pi / 180.0
+	pow7 := func(v float64) float64 { return v * v * v * v * v * v * v }
+
+	c1, c2 := math.Hypot(lab1[1], lab1[2]), math.Hypot(lab2[1], lab2[2])
+	c_mean := (c1 + c2) / 2.0
+	g := 0.5 * (1.0 - math.Sqrt(pow7(c_mean) / (pow7(c_mean) + pow7(25.0))))
+	a1, a2 := (1.0 + g) * lab1[1], (1.0 + g) * lab2[1]
+	c1p, c2p := math.Hypot(a1, lab1[2]), math.Hypot(a2, lab2[2])
+
+	hue := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a)
+		if h < 0 {
+			h += 2.0 * math.Pi
+		}
+		return h
+	}
+	h1p, h2p := hue(lab1[2], a1), hue(lab2[2], a2)
+
+	delta_l := lab2[0] - lab1[0]
+	delta_c := c2p - c1p
+	delta_h := 0.0
+	if c1p * c2p != 0 {
+		delta_h = h2p - h1p
+		if delta_h > math.Pi {
+			delta_h -= 2.0 * math.Pi
+		} else if delta_h < -math.Pi {
+			delta_h += 2.0 * math.Pi
+		}
+	}
+	delta_hue := 2.0 * math.Sqrt(c1p * c2p) * math.Sin(delta_h / 2.0)
+
+	l_mean := (lab1[0] + lab2[0]) / 2.0
+	c_mean_p := (c1p + c2p) / 2.0
+	h_mean := h1p + h2p
+	if c1p * c2p != 0 {
+		if math.Abs(h1p - h2p) > math.Pi {
+			if h_mean < 2.0 * math.Pi {
+				h_mean += 2.0 * math.Pi
+			} else {
+				h_mean -= 2.0 * math.Pi
+			}
+		}
+		h_mean /= 2.0
+	}
+
+	t := 1.0 - 0.17 * math.Cos(h_mean - 30.0 * deg) + 0.24 * math.Cos(2.0 * h_mean) +
+		0.32 * math.Cos(3.0 * h_mean + 6.0 * deg) - 0.20 * math.Cos(4.0 * h_mean - 63.0 * deg)
+	delta_theta := 30.0 * deg * math.Exp(-math.Pow((h_mean / deg - 275.0) / 25.0, 2))
+	r_c := 2.0 * math.Sqrt(pow7(c_mean_p) / (pow7(c_mean_p) + pow7(25.0)))
+	l_shift := (l_mean - 50.0) * (l_mean - 50.0)
+	s_l := 1.0 + 0.015 * l_shift / math.Sqrt(20.0 + l_shift)
+	s_c := 1.0 + 0.045 * c_mean_p
+	s_h := 1.0 + 0.015 * c_mean_p * t
+	r_t := -math.Sin(2.0 * delta_theta) * r_c
+
+	term_l, term_c, term_h := delta_l / s_l, delta_c / s_c, delta_hue / s_h
+	return math.Sqrt(term_l * term_l + term_c * term_c + term_h * term_h + r_t * term_c * term_h)
+}
@@ -1,6 +1,9 @@
 package contrast
 
-import "image"
+import (
+	"image"
+	"math"
+)
 
 /*
 	Given binary (thresholded) grayscale image of edges detected,
@@ -23,4 +26,52 @@ func EdgesToEdgeCounts(edges_binary *image.Gray) []uint {
 	}
 	return edge_counts
 
-}
\ No newline at end of file
+}
+
+/*
+	Same as EdgesToEdgeCounts for COUNT_PIXELS mode, other COUNT_ modes measure runs of consecutive edge pixels
+	along each position instead, so scattered fragments (noise, watermarks) score lower than one continuous gridline.
+*/
+func EdgeCountsWithMode(edges_binary *image.Gray, mode uint8) []uint {
+	if mode == COUNT_PIXELS {
+		return EdgesToEdgeCounts(edges_binary)
+	}
+
+	height, width := edges_binary.Rect.Dy(), edges_binary.Rect.Dx()
+	edge_positions_count := width - 1
+	edge_counts := make([]uint, edge_positions_count)
+
+	// runs are tracked for all positions at once, so the image is still read row by row
+	current_runs := make([]uint, edge_positions_count)
+	squared_sums := make([]uint, edge_positions_count)
+	finish_run := func(x int) {
+		run := current_runs[x]
+		if mode == COUNT_LONGEST_RUN {
+			edge_counts[x] = max(edge_counts[x], run)
+		} else {
+			squared_sums[x] += run * run
+		}
+		current_runs[x] = 0
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < edge_positions_count; x++ {
+			flat_id := edges_binary.PixOffset(x + edges_binary.Rect.Min.X, y + edges_binary.Rect.Min.Y)
+			if edges_binary.Pix[flat_id] != 0 {
+				current_runs[x] += 1
+			} else if current_runs[x] > 0 {
+				finish_run(x)
+			}
+		}
+	}
+	for x := 0; x < edge_positions_count; x++ {
+		finish_run(x)
+	}
+
+	if mode == COUNT_SQUARED_RUNS {
+		for x, squared_sum := range squared_sums {
+			edge_counts[x] = uint(math.Round(math.Sqrt(float64(squared_sum))))
+		}
+	}
+	return edge_counts
+}
@@ -22,6 +22,10 @@ If vertical == true,
 
 First value of each row is 0 padding used to preserve image shape
 
+Alpha channel counts like three colour channels, so a change from fully transparent to opaque is the strongest edge
+regardless of colour. Colour channels are clamped to alpha (image.RGBA is alpha-premultiplied),
+so fully transparent pixels are equal whatever colour values they hold.
+
 */
 func CalculatePixelEdgeDistances(img *image.RGBA, vertical bool) *image.Gray{
 	var is_vertical int = common.Ternary(vertical, 1, 0)
@@ -50,10 +54,13 @@ func CalculatePixelEdgeDistances(img *image.RGBA, vertical bool) *image.Gray{
 				next[1 - is_vertical] + img.Rect.Min.Y,
 			)
 
-			var r_delta float64 = float64(img.Pix[curr_flat_id + 0]) - float64(img.Pix[next_flat_id + 0])
-			var g_delta float64 = float64(img.Pix[curr_flat_id + 1]) - float64(img.Pix[next_flat_id + 1])
-			var b_delta float64 = float64(img.Pix[curr_flat_id + 2]) - float64(img.Pix[next_flat_id + 2])
-			dist := math.Sqrt(r_delta * r_delta + g_delta *g_delta + b_delta * b_delta)
+			curr_color := premultipliedColor(img.Pix, curr_flat_id)
+			next_color := premultipliedColor(img.Pix, next_flat_id)
+			var r_delta float64 = curr_color[0] - next_color[0]
+			var g_delta float64 = curr_color[1] - next_color[1]
+			var b_delta float64 = curr_color[2] - next_color[2]
+			var a_delta float64 = curr_color[3] - next_color[3]
+			dist := math.Sqrt(r_delta * r_delta + g_delta *g_delta + b_delta * b_delta + 3.0 * a_delta * a_delta)
 
 			new_data[outer * new_stride + inner + 1] = distMapToUint8(dist)
 		}
@@ -72,5 +79,19 @@ func CalculatePixelEdgeDistances(img *image.RGBA, vertical bool) *image.Gray{
 
 func distMapToUint8(dist float64) uint8 {
 	const max_possible_color_diff = 441.674
-	return uint8(255.0 * dist / max_possible_color_diff + 0.5)
+	return uint8(min(255.0 * dist / max_possible_color_diff + 0.5, 255.0))
+}
+
+/*
+	Returns RGBA channels of the pixel at flat_id with colour channels clamped to alpha,
+	valid alpha-premultiplied data is returned unchanged
+*/
+func premultipliedColor(pix []uint8, flat_id int) [4]float64 {
+	alpha := pix[flat_id + 3]
+	return [4]float64{
+		float64(min(pix[flat_id + 0], alpha)),
+		float64(min(pix[flat_id + 1], alpha)),
+		float64(min(pix[flat_id + 2], alpha)),
+		float64(alpha),
+	}
 }
\ No newline at end of file
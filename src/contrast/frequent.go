@@ -7,6 +7,45 @@ import (
 	"sort"
 )
 
+/*
+	Defines a set of uint8 constants used to select how edge pixels at one position are counted, see EdgeCountsWithMode
+	COUNT_PIXELS:
+		number of edge pixels, fragments count the same as one continuous line of the same total length
+	COUNT_LONGEST_RUN:
+		length of the longest run of consecutive edge pixels
+	COUNT_SQUARED_RUNS:
+		square root of sum of squared run lengths, equals COUNT_PIXELS for one continuous line and drops with fragmentation
+*/
+const (
+	COUNT_PIXELS uint8 = iota
+	COUNT_LONGEST_RUN uint8 = iota
+	COUNT_SQUARED_RUNS uint8 = iota
+)
+
+var count_mode_names = [3]string{"pixels", "longest-run", "squared-runs"}
+
+/*
+	Returns short name of a COUNT_ constant, used in reports and command line flags
+*/
+func CountModeName(mode uint8) string {
+	if int(mode) >= len(count_mode_names) {
+		return "unknown"
+	}
+	return count_mode_names[mode]
+}
+
+/*
+	Returns COUNT_ constant with given name and true, or false if no count mode has that name
+*/
+func CountModeFromName(name string) (uint8, bool) {
+	for mode, mode_name := range count_mode_names {
+		if mode_name == name {
+			return uint8(mode), true
+		}
+	}
+	return 0, false
+}
+
 /*
 	ClipTop: float32
 		(After filtering out positions with 0 edges)
@@ -14,6 +53,8 @@ import (
 	CutoffMultiplier: float32
 		(After applying both clip top)
 		The lowest permissable edge count for a position is set to CutoffMultiplier * 100% *<most common edge position count>)
+	CountMode: uint8
+		One of COUNT_ constants, decides how edge pixels are turned into counts before selection, see EdgeCountsWithMode
 
 	Constraints:
 		0 <= ClipTop < 1.0
@@ -22,6 +63,7 @@ import (
 type MostFrequentParams struct {
 	ClipTop float32
 	CutoffMultiplier float32
+	CountMode uint8
 }
 
 
@@ -29,6 +71,7 @@ func GetBaseMostFrequentParams() MostFrequentParams {
 	return MostFrequentParams{
 		ClipTop: 0.2,
 		CutoffMultiplier: 0.3,
+		CountMode: COUNT_PIXELS,
 	}
 }
 
@@ -41,6 +84,10 @@ func SelectMostFrequent(edge_counts []uint, params MostFrequentParams) []int {
 		return []int{}
 	}	
 	counter := edgeCountsSortedNonzero(edge_counts)
+	// cleanup may leave no edges at all
+	if len(counter) == 0 {
+		return []int{}
+	}
 	
 	clip_amount := int(params.ClipTop * float32(len(counter)))
 	// index of highest value after clipping
@@ -0,0 +1,111 @@
+package contrast
+
+import (
+	"image"
+	"math"
+)
+
+/*
+	MaxAngle: float64
+		Largest detected rotation in degrees, angles within [-MaxAngle, MaxAngle] are tried
+	AngleStep: float64
+		Step between tried angles in degrees, the best angle is refined below the step by parabolic interpolation
+
+	Constraints:
+		0 < AngleStep <= MaxAngle
+*/
+type HoughParams struct {
+	MaxAngle float64
+	AngleStep float64
+}
+
+func GetBaseHoughParams() HoughParams {
+	return HoughParams{
+		MaxAngle: 5.0,
+		AngleStep: 0.1,
+	}
+}
+
+/*
+	DetectGridRotation estimates rotation of gridlines from binary edge maps of both axes
+	(outputs of ThresholdWithMinHeight, ThresholdAdaptive or CleanupEdgeArtifacts for vertical == false and vertical == true).
+
+	Both maps hold gridlines as nearly vertical lines, so a Hough transform restricted to angles around vertical is used:
+	every edge pixel votes for line rho = x * cos(theta) + y * sin(theta) and the angle whose votes are concentrated
+	in the fewest lines (largest sum of squared votes) wins. Maps are transposed relative to each other,
+	so the same image rotation tilts their lines in opposite directions.
+
+	Returns rotation in degrees in the convention of images.ImageGetRotated (rotating the image by minus this angle
+	straightens the grid) and strength, the vote concentration at the returned angle divided by the one at 0 degrees.
+	Returns 0, 1 if maps contain no edges.
+*/
+func DetectGridRotation(edges_rows, edges_cols *image.Gray, params HoughParams) (float64, float64) {
+	step_count := int(params.MaxAngle / params.AngleStep)
+	if step_count < 1 {
+		return 0.0, 1.0
+	}
+
+	scores := make([]float64, 2 * step_count + 1)
+	for i := range scores {
+		angle := float64(i - step_count) * params.AngleStep
+		scores[i] = houghConcentration(edges_rows, -angle) + houghConcentration(edges_cols, angle)
+	}
+
+	best := step_count
+	for i, score := range scores {
+		if score > scores[best] {
+			best = i
+		}
+	}
+	if scores[step_count] <= 0 {
+		return 0.0, 1.0
+	}
+
+	offset := 0.0
+	if best > 0 && best < len(scores) - 1 {
+		left, centre, right := scores[best - 1], scores[best], scores[best + 1]
+		denominator := left - 2.0 * centre + right
+		if denominator < 0 {
+			offset = 0.5 * (left - right) / denominator
+		}
+	}
+
+	angle := (float64(best - step_count) + offset) * params.AngleStep
+	return angle, scores[best] / scores[step_count]
+}
+
+/*
+	Accumulates votes of all non-zero pixels for lines of given angle (degrees from vertical)
+	and returns sum of squared votes divided by number of voting pixels.
+*/
+func houghConcentration(edges_binary *image.Gray, angle float64) float64 {
+	height, width := edges_binary.Rect.Dy(), edges_binary.Rect.Dx()
+	radians := angle * math.Pi / 180.0
+	sin, cos := math.Sin(radians), math.Cos(radians)
+
+	// rho lies within [-height, width + height] for angles up to 90 degrees
+	offset := height
+	accumulator := make([]float64, width + 2 * height + 1)
+
+	var voters float64 = 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			flat_id := edges_binary.PixOffset(x + edges_binary.Rect.Min.X, y + edges_binary.Rect.Min.Y)
+			if edges_binary.Pix[flat_id] == 0 {
+				continue
+			}
+			rho := int(math.Round(float64(x) * cos + float64(y) * sin))
+			accumulator[rho + offset] += 1
+			voters += 1
+		}
+	}
+	if voters == 0 {
+		return 0.0
+	}
+
+	var sum_squares float64 = 0
+	for _, votes := range accumulator {
+		sum_squares += votes * votes
+	}
+	return sum_squares / voters
+}
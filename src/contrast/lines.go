@@ -0,0 +1,87 @@
+package contrast
+
+import (
+	"image"
+)
+
+import (
+	"pixel_restoration/common"
+)
+
+/*
+	Returns colour and strength of gridline pixels at every position of an axis: columns of X axis,
+	or rows of Y axis if vertical is true, the same orientation CalculatePixelEdgeDistances uses.
+
+	A pixel belongs to a gridline if its colour differs by more than tolerance in any channel from both pixels
+	distance positions before and after it. With distance of about half a cell those pixels lie inside neighbouring cells,
+	so pixels on a cell border of the art match one of them, while gridlines drawn over or under the art match neither.
+	Colour of a position is the median of each channel over its gridline pixels, strength is the fraction
+	of its pixels that belong to a gridline and are within tolerance of that colour, so gridlines blended with the art
+	are weaker than solid ones. Positions closer than distance to the image border have strength 0.
+*/
+func GridlineColors(img *image.RGBA, vertical bool, distance int, tolerance uint8) ([][4]uint8, []float64) {
+	length, cross_length := img.Rect.Dx(), img.Rect.Dy()
+	if vertical {
+		length, cross_length = cross_length, length
+	}
+	pixel_at := func(position, cross int) [4]uint8 {
+		if vertical {
+			return common.PixelColor(img, cross, position)
+		}
+		return common.PixelColor(img, position, cross)
+	}
+
+	is_line_pixel := func(position, cross int, color [4]uint8) bool {
+		return !common.ColorsClose(color, pixel_at(position - distance, cross), tolerance) &&
+			!common.ColorsClose(color, pixel_at(position + distance, cross), tolerance)
+	}
+
+	colors := make([][4]uint8, length)
+	strengths := make([]float64, length)
+	if cross_length == 0 || distance < 1 {
+		return colors, strengths
+	}
+
+	var histograms [4][256]int
+	for position := distance; position < length - distance; position++ {
+		for channel := range histograms {
+			clear(histograms[channel][:])
+		}
+		line_pixels := 0
+		for cross := 0; cross < cross_length; cross++ {
+			color := pixel_at(position, cross)
+			if !is_line_pixel(position, cross, color) {
+				continue
+			}
+			for channel := 0; channel < 4; channel++ {
+				histograms[channel][color[channel]] += 1
+			}
+			line_pixels += 1
+		}
+		if line_pixels == 0 {
+			continue
+		}
+
+		for channel := 0; channel < 4; channel++ {
+			below := 0
+			for value := 0; value < 256; value++ {
+				below += histograms[channel][value]
+				if 2 * below > line_pixels {
+					colors[position][channel] = uint8(value)
+					break
+				}
+			}
+		}
+
+		// gridlines blended with the art change colour along the line, only pixels of the median colour count
+		matching := 0
+		for cross := 0; cross < cross_length; cross++ {
+			color := pixel_at(position, cross)
+			if common.ColorsClose(color, colors[position], tolerance) && is_line_pixel(position, cross, color) {
+				matching += 1
+			}
+		}
+		strengths[position] = float64(matching) / float64(cross_length)
+	}
+	return colors, strengths
+}
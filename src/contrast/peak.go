@@ -27,13 +27,65 @@ func CalculateMinPeakHeight(all_distances []uint8, params PeakHeightParams) uint
 /*
 	Mean peak height limit allows to set the limit on the return value of min peak height function
 	If function is about to return a value higher than this parameter, it gets truncated to this value.
+
+	Mode: uint8
+		One of THRESHOLD_ constants, selects how distances are thresholded, see ThresholdAdaptive
+	TileSize: int
+		Side of square tiles thresholded separately by THRESHOLD_TILE
+	MinLocalFraction: float64
+		Adaptive thresholds never drop below this fraction of the global min peak height,
+		so flat lines and tiles don't turn noise into edges
+
+	Constraints:
+		TileSize >= 1
+		0 < MinLocalFraction <= 1
 */
 type PeakHeightParams struct {
 	MinPeakHeightLimit float64
+	Mode uint8
+	TileSize int
+	MinLocalFraction float64
 }
 
 func GetBasePeakHeightParams() PeakHeightParams{
 	return PeakHeightParams{
 		MinPeakHeightLimit : 58.0,
+		Mode: THRESHOLD_GLOBAL,
+		TileSize: 64,
+		MinLocalFraction: 0.25,
 	}
-}
\ No newline at end of file
+}
+
+/*
+	Enabled: bool
+		If true, thresholded edges are reduced to true peaks of distance rows, see SuppressNonMaxima
+	MinProminence: float64
+		Peaks must rise at least this much (in distance units 0 - 255) above the higher of the lowest points
+		that separate them from a higher peak (or the row end) on both sides
+	MinDistance: int
+		Peaks closer than this many positions are suppressed, weaker ones first, thin lines excepted (see LineFraction)
+	LineFraction: float64
+		Two adjacent edge positions are both kept if colours on their outer sides differ by less than this fraction
+		of the sum of both edges, the pixel between them stands out of both neighbours, so they are both sides
+		of a 1 pixel wide gridline. Colours of a smeared edge change monotonically and the outer difference equals the sum.
+
+	Constraints:
+		MinProminence >= 0
+		MinDistance >= 1
+		0 <= LineFraction <= 1
+*/
+type PeakParams struct {
+	Enabled bool
+	MinProminence float64
+	MinDistance int
+	LineFraction float64
+}
+
+func GetBasePeakParams() PeakParams {
+	return PeakParams{
+		Enabled: false,
+		MinProminence: 8.0,
+		MinDistance: 2,
+		LineFraction: 0.9,
+	}
+}
@@ -0,0 +1,148 @@
+package contrast
+
+import (
+	"image"
+	"slices"
+)
+
+import (
+	"pixel_restoration/common"
+)
+
+/*
+	SuppressNonMaxima reduces edges of a binary map to peaks of the distances they were thresholded from.
+	Anti-aliased or JPEG-smeared edges span 2-3 adjacent positions above the threshold, only the peak of every edge is kept,
+	so each physical edge contributes exactly one position to edge counts.
+
+	Distances and edges_binary are maps of the same shape made by CalculatePixelEdgeDistancesWithMetric for the same
+	vertical flag (the vertical map is transposed), img is the image and metric the METRIC_ constant the distances
+	were computed with, unknown metrics fall back to METRIC_RGB.
+	In every row, edge positions that are local maxima of distances are kept, plateaus keep their middle position.
+	Peaks with prominence lower than params.MinProminence are removed, then peaks closer than params.MinDistance
+	are removed, weaker ones first. Adjacent edge positions forming a 1 pixel wide line are kept together,
+	see PeakParams.LineFraction. Returns a new binary map.
+*/
+func SuppressNonMaxima(
+	img *image.RGBA, distances, edges_binary *image.Gray, vertical bool, metric uint8, params PeakParams,
+) *image.Gray {
+	result := image.NewGray(image.Rect(0, 0, edges_binary.Rect.Dx(), edges_binary.Rect.Dy()))
+	width, height := result.Rect.Dx(), result.Rect.Dy()
+	var is_vertical int = common.Ternary(vertical, 1, 0)
+
+	// colour distance of pixels at positions first and second of the row, same as distances of adjacent pixels
+	converted := convertColors(img, metric)
+	pixel_distance := func(row, first, second int) float64 {
+		first_point, second_point := [2]int{first, row}, [2]int{second, row}
+		first_id := first_point[1 - is_vertical] * img.Rect.Dx() + first_point[is_vertical]
+		second_id := second_point[1 - is_vertical] * img.Rect.Dx() + second_point[is_vertical]
+		return scaledColorDistance(converted[first_id], converted[second_id], metric)
+	}
+
+	profile := make([]float64, width)
+	is_edge := make([]bool, width)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			profile[x] = float64(distances.Pix[distances.PixOffset(x + distances.Rect.Min.X, y + distances.Rect.Min.Y)])
+			is_edge[x] = edges_binary.Pix[edges_binary.PixOffset(x + edges_binary.Rect.Min.X, y + edges_binary.Rect.Min.Y)] != 0
+		}
+
+		peaks := rowPeaks(profile, is_edge, params)
+		for _, peak := range peaks {
+			result.Pix[y * result.Stride + peak] = 255
+			// map position x holds distance of pixels x-1 and x, a 1 pixel line at pixel x has edges at x and x+1
+			for _, neighbour := range [2]int{peak - 1, peak + 1} {
+				first, last := min(peak, neighbour), max(peak, neighbour)
+				if first < 1 || last >= width || !is_edge[neighbour] {
+					continue
+				}
+				outer := pixel_distance(y, first - 1, last)
+				if outer < params.LineFraction * (profile[first] + profile[last]) {
+					result.Pix[y * result.Stride + neighbour] = 255
+				}
+			}
+		}
+	}
+	return result
+}
+
+/*
+	Returns sorted positions of peaks of one distance row that lie on edge positions, see SuppressNonMaxima
+*/
+func rowPeaks(profile []float64, is_edge []bool, params PeakParams) []int {
+	peaks := make([]int, 0)
+	for x := 0; x < len(profile); {
+		if !is_edge[x] {
+			x += 1
+			continue
+		}
+		// plateau of equal values starting at x
+		plateau_end := x
+		for plateau_end + 1 < len(profile) && profile[plateau_end + 1] == profile[x] {
+			plateau_end += 1
+		}
+		rises := x == 0 || profile[x - 1] < profile[x]
+		falls := plateau_end == len(profile) - 1 || profile[plateau_end + 1] < profile[x]
+		if rises && falls {
+			middle := (x + plateau_end) / 2
+			if is_edge[middle] && peakProminence(profile, x, plateau_end) >= params.MinProminence {
+				peaks = append(peaks, middle)
+			}
+		}
+		x = plateau_end + 1
+	}
+
+	if params.MinDistance <= 1 || len(peaks) < 2 {
+		return peaks
+	}
+
+	// stronger peaks claim their neighbourhood first, equal ones keep their left to right order
+	order := make([]int, len(peaks))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if profile[peaks[a]] > profile[peaks[b]] {
+			return -1
+		}
+		if profile[peaks[a]] < profile[peaks[b]] {
+			return 1
+		}
+		return 0
+	})
+	removed := make([]bool, len(peaks))
+	for _, id := range order {
+		if removed[id] {
+			continue
+		}
+		for other := id - 1; other >= 0 && peaks[id] - peaks[other] < params.MinDistance; other-- {
+			removed[other] = true
+		}
+		for other := id + 1; other < len(peaks) && peaks[other] - peaks[id] < params.MinDistance; other++ {
+			removed[other] = true
+		}
+	}
+
+	kept := peaks[:0]
+	for id, peak := range peaks {
+		if !removed[id] {
+			kept = append(kept, peak)
+		}
+	}
+	return kept
+}
+
+/*
+	Returns prominence of the plateau [start, end] of the profile: its height minus the higher of the lowest values
+	found on each side before reaching a higher value or the end of the profile
+*/
+func peakProminence(profile []float64, start, end int) float64 {
+	height := profile[start]
+	left_min, right_min := height, height
+	for x := start - 1; x >= 0 && profile[x] <= height; x-- {
+		left_min = min(left_min, profile[x])
+	}
+	for x := end + 1; x < len(profile) && profile[x] <= height; x++ {
+		right_min = min(right_min, profile[x])
+	}
+	return height - max(left_min, right_min)
+}
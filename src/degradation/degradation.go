@@ -0,0 +1,276 @@
+package degradation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"math/rand"
+)
+
+import (
+	"pixel_restoration/images"
+)
+
+/*
+	Params describes how a clean low resolution sprite is turned into a degraded test image.
+	Degradations are applied in the following order: upscale, rescale, crop, watermark, noise, JPEG.
+
+	PixelSize, GridSize, GridColor:
+		Parameters of images.AdvancedUpscale. PixelSize must be larger than 0, GridSize 0 means no gridlines.
+	ScaleX, ScaleY: float64
+		Non-integer rescaling factors applied after upscale, 1.0 disables rescaling on the axis.
+	Interpolation: uint8
+		One of images.INTERPOLATION_ constants used for rescaling.
+	CropFraction: float64
+		Largest fraction of each dimension that is randomly cut off from each side of the image, 0 disables cropping.
+	GaussianNoiseSigma: float64
+		Standard deviation of gaussian noise added to colour channels, 0 disables the noise.
+	SaltPepperAmount: float64
+		Fraction of pixels set to black or white, 0 disables the noise.
+	WatermarkText: string
+		Text drawn with built-in bitmap font, empty string disables text watermark.
+	WatermarkLogo: *image.RGBA
+		Image drawn as a watermark using its alpha channel, nil disables logo watermark.
+	WatermarkScale: int
+		Size of one font pixel of text watermark in image pixels.
+	WatermarkOpacity: float64
+		Opacity of watermarks (0.0 - 1.0).
+	WatermarkCount: int
+		How many times each enabled watermark is drawn at random positions.
+	JPEGQuality: int
+		Quality of JPEG re-encoding (1 - 100), 0 disables re-encoding.
+	Seed: int64
+		Seed of all random decisions, the same seed and params always produce the same image.
+*/
+type Params struct {
+	PixelSize uint
+	GridSize uint
+	GridColor [4]uint8
+
+	ScaleX float64
+	ScaleY float64
+	Interpolation uint8
+
+	CropFraction float64
+
+	GaussianNoiseSigma float64
+	SaltPepperAmount float64
+
+	WatermarkText string
+	WatermarkLogo *image.RGBA
+	WatermarkScale int
+	WatermarkOpacity float64
+	WatermarkCount int
+
+	JPEGQuality int
+
+	Seed int64
+}
+
+func GetBaseParams() Params {
+	return Params{
+		PixelSize: 8,
+		GridSize: 0,
+		GridColor: [4]uint8{0, 0, 0, 255},
+		ScaleX: 1.0,
+		ScaleY: 1.0,
+		Interpolation: images.INTERPOLATION_BILINEAR,
+		CropFraction: 0.0,
+		GaussianNoiseSigma: 0.0,
+		SaltPepperAmount: 0.0,
+		WatermarkText: "",
+		WatermarkLogo: nil,
+		WatermarkScale: 2,
+		WatermarkOpacity: 0.5,
+		WatermarkCount: 1,
+		JPEGQuality: 0,
+		Seed: 1,
+	}
+}
+
+/*
+	GroundTruth describes the exact art pixel lattice of a degraded image.
+	All arrays are ordered {X axis, Y axis}, all sizes are in pixels of the degraded image.
+
+	ArtSize:
+		Width and height of the original sprite in art pixels.
+	PixelSize, GridSize:
+		Size of art pixel cells and width of gridlines, fractional if the image was rescaled.
+	Period:
+		Distance between starts of two consecutive art pixel cells, equal to PixelSize + GridSize
+	Offset:
+		Position where art pixel cell with index FirstCell starts, always in range [0, Period).
+		Cell with index FirstCell + n starts at Offset + n * Period.
+	FirstCell:
+		Index of the first art pixel (column or row of the sprite) whose cell starts inside the image.
+	Scale:
+		Scale factors that were actually applied, after rounding the image size to whole pixels.
+	Crop:
+		Rectangle cut out of the rescaled image.
+*/
+type GroundTruth struct {
+	ArtSize [2]int
+	PixelSize [2]float64
+	GridSize [2]float64
+	Period [2]float64
+	Offset [2]float64
+	FirstCell [2]int
+	Scale [2]float64
+	Crop image.Rectangle
+}
+
+/*
+	Degrade upscales a clean sprite and applies degradations described in params.
+	Returns degraded image along with ground truth of its art pixel lattice.
+	Sprite is not modified.
+*/
+func Degrade(sprite *image.RGBA, params Params) (*image.RGBA, GroundTruth, error) {
+	var truth GroundTruth
+	if sprite == nil || sprite.Rect.Empty() {
+		return nil, truth, errors.New("degradation: sprite is empty")
+	}
+	if params.PixelSize == 0 {
+		return nil, truth, errors.New("degradation: pixel size must be larger than 0")
+	}
+	if params.ScaleX <= 0 || params.ScaleY <= 0 {
+		return nil, truth, errors.New("degradation: scale factors must be positive")
+	}
+	if params.JPEGQuality < 0 || params.JPEGQuality > 100 {
+		return nil, truth, fmt.Errorf("degradation: JPEG quality %d outside of range 0-100", params.JPEGQuality)
+	}
+
+	rng := rand.New(rand.NewSource(params.Seed))
+
+	img, err := images.AdvancedUpscaleGetNewImage(sprite, params.PixelSize, params.GridSize, params.GridColor)
+	if err != nil {
+		return nil, truth, fmt.Errorf("degradation: %w", err)
+	}
+	upscaled_width, upscaled_height := img.Rect.Dx(), img.Rect.Dy()
+
+	// rescaling
+	if params.ScaleX != 1.0 || params.ScaleY != 1.0 {
+		new_width := max(1, int(math.Round(float64(upscaled_width) * params.ScaleX)))
+		new_height := max(1, int(math.Round(float64(upscaled_height) * params.ScaleY)))
+		img = images.ImageGetResized(img, new_width, new_height, params.Interpolation)
+	}
+	truth.Scale = [2]float64{
+		float64(img.Rect.Dx()) / float64(upscaled_width),
+		float64(img.Rect.Dy()) / float64(upscaled_height),
+	}
+
+	// cropping
+	truth.Crop = randomCropRectangle(img.Rect, params.CropFraction, rng)
+	img = images.ImageGetNormalized(img.SubImage(truth.Crop).(*image.RGBA))
+
+	// watermarks
+	for i := 0; i < params.WatermarkCount; i++ {
+		if params.WatermarkText != "" {
+			text_img := renderText(params.WatermarkText, params.WatermarkScale, [4]uint8{255, 255, 255, 255})
+			images.ImageBlendOver(img, text_img, randomPosition(img.Rect, text_img.Rect, rng), params.WatermarkOpacity)
+		}
+		if params.WatermarkLogo != nil {
+			images.ImageBlendOver(img, params.WatermarkLogo, randomPosition(img.Rect, params.WatermarkLogo.Rect, rng), params.WatermarkOpacity)
+		}
+	}
+
+	// noise
+	if params.GaussianNoiseSigma > 0 {
+		images.ImageAddGaussianNoise(img, params.GaussianNoiseSigma, rng)
+	}
+	if params.SaltPepperAmount > 0 {
+		images.ImageAddSaltPepperNoise(img, params.SaltPepperAmount, rng)
+	}
+
+	// compression
+	if params.JPEGQuality > 0 {
+		var err error
+		img, err = reencodeJPEG(img, params.JPEGQuality)
+		if err != nil {
+			return nil, truth, err
+		}
+	}
+
+	fillLatticeTruth(&truth, sprite.Rect, params)
+	return img, truth, nil
+}
+
+/*
+	Computes art pixel lattice of degraded image from upscale parameters, applied scale and crop.
+	Cell i of the upscaled image starts at GridSize + i * (PixelSize + GridSize), which is then scaled and shifted by crop.
+*/
+func fillLatticeTruth(truth *GroundTruth, sprite_rect image.Rectangle, params Params) {
+	truth.ArtSize = [2]int{sprite_rect.Dx(), sprite_rect.Dy()}
+	crop_min := [2]int{truth.Crop.Min.X, truth.Crop.Min.Y}
+
+	for axis := 0; axis < 2; axis++ {
+		scale := truth.Scale[axis]
+		truth.PixelSize[axis] = float64(params.PixelSize) * scale
+		truth.GridSize[axis] = float64(params.GridSize) * scale
+		truth.Period[axis] = truth.PixelSize[axis] + truth.GridSize[axis]
+
+		first_start := truth.GridSize[axis] - float64(crop_min[axis])
+		first_cell := int(math.Ceil(-first_start / truth.Period[axis]))
+		first_cell = max(first_cell, 0)
+
+		truth.FirstCell[axis] = first_cell
+		truth.Offset[axis] = first_start + float64(first_cell) * truth.Period[axis]
+	}
+}
+
+/*
+	Returns a rectangle inside of <bounds> with up to <fraction> of each dimension cut off from each side.
+	Returned rectangle is never empty.
+*/
+func randomCropRectangle(bounds image.Rectangle, fraction float64, rng *rand.Rand) image.Rectangle {
+	if fraction <= 0 {
+		return bounds
+	}
+	max_crop_x := int(float64(bounds.Dx()) * min(fraction, 0.49))
+	max_crop_y := int(float64(bounds.Dy()) * min(fraction, 0.49))
+
+	left, right := rng.Intn(max_crop_x + 1), rng.Intn(max_crop_x + 1)
+	top, bottom := rng.Intn(max_crop_y + 1), rng.Intn(max_crop_y + 1)
+
+	return image.Rect(bounds.Min.X + left, bounds.Min.Y + top, bounds.Max.X - right, bounds.Max.Y - bottom)
+}
+
+/*
+	Returns random top left position for overlay, so that overlay fits in the image if possible
+*/
+func randomPosition(bounds, overlay image.Rectangle, rng *rand.Rand) image.Point {
+	free_x := max(bounds.Dx() - overlay.Dx(), 0)
+	free_y := max(bounds.Dy() - overlay.Dy(), 0)
+	return image.Point{rng.Intn(free_x + 1), rng.Intn(free_y + 1)}
+}
+
+/*
+	Encodes the image as JPEG with given quality and decodes it back
+*/
+func reencodeJPEG(img *image.RGBA, quality int) (*image.RGBA, error) {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	decoded, err := jpeg.Decode(&buffer)
+	if err != nil {
+		return nil, err
+	}
+	return images.RGBAFromImage(decoded), nil
+}
+
+/*
+	Returns file name following the test set naming convention (see evaluation.ParseTestFilename),
+	so that degraded images can be evaluated like any other test image.
+	Sizes of X axis are used, grided images are named GRIDED_<grid>_<pixel>_<name>.png, others CLEAN_<pixel>_<name>.png
+*/
+func TestFilename(truth GroundTruth, name string) string {
+	pixel := math.Round(truth.PixelSize[0] * 100.0) / 100.0
+	grid := math.Round(truth.GridSize[0] * 100.0) / 100.0
+	if grid > 0 {
+		return fmt.Sprintf("GRIDED_%g_%g_%s.png", grid, pixel, name)
+	}
+	return fmt.Sprintf("CLEAN_%g_%s.png", pixel, name)
+}
@@ -0,0 +1,92 @@
+package degradation
+
+import (
+	"image"
+	"unicode"
+)
+
+const glyph_width int = 3
+const glyph_height int = 5
+
+/*
+	Minimal 3x5 bitmap font used for text watermarks.
+	Each glyph is 5 rows, lowest 3 bits of each row describe pixels from left to right.
+	Lowercase letters are drawn as uppercase, unsupported characters are drawn as blanks.
+*/
+var font_glyphs = map[rune][glyph_height]uint8{
+	'A': {0b010, 0b101, 0b111, 0b101, 0b101},
+	'B': {0b110, 0b101, 0b110, 0b101, 0b110},
+	'C': {0b011, 0b100, 0b100, 0b100, 0b011},
+	'D': {0b110, 0b101, 0b101, 0b101, 0b110},
+	'E': {0b111, 0b100, 0b110, 0b100, 0b111},
+	'F': {0b111, 0b100, 0b110, 0b100, 0b100},
+	'G': {0b011, 0b100, 0b101, 0b101, 0b011},
+	'H': {0b101, 0b101, 0b111, 0b101, 0b101},
+	'I': {0b111, 0b010, 0b010, 0b010, 0b111},
+	'J': {0b001, 0b001, 0b001, 0b101, 0b010},
+	'K': {0b101, 0b101, 0b110, 0b101, 0b101},
+	'L': {0b100, 0b100, 0b100, 0b100, 0b111},
+	'M': {0b101, 0b111, 0b111, 0b101, 0b101},
+	'N': {0b110, 0b101, 0b101, 0b101, 0b101},
+	'O': {0b010, 0b101, 0b101, 0b101, 0b010},
+	'P': {0b110, 0b101, 0b110, 0b100, 0b100},
+	'Q': {0b010, 0b101, 0b101, 0b110, 0b011},
+	'R': {0b110, 0b101, 0b110, 0b101, 0b101},
+	'S': {0b011, 0b100, 0b010, 0b001, 0b110},
+	'T': {0b111, 0b010, 0b010, 0b010, 0b010},
+	'U': {0b101, 0b101, 0b101, 0b101, 0b111},
+	'V': {0b101, 0b101, 0b101, 0b101, 0b010},
+	'W': {0b101, 0b101, 0b111, 0b111, 0b101},
+	'X': {0b101, 0b101, 0b010, 0b101, 0b101},
+	'Y': {0b101, 0b101, 0b010, 0b010, 0b010},
+	'Z': {0b111, 0b001, 0b010, 0b100, 0b111},
+	'0': {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1': {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2': {0b110, 0b001, 0b010, 0b100, 0b111},
+	'3': {0b110, 0b001, 0b010, 0b001, 0b110},
+	'4': {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5': {0b111, 0b100, 0b110, 0b001, 0b110},
+	'6': {0b011, 0b100, 0b111, 0b101, 0b111},
+	'7': {0b111, 0b001, 0b010, 0b010, 0b010},
+	'8': {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9': {0b111, 0b101, 0b111, 0b001, 0b110},
+	'.': {0b000, 0b000, 0b000, 0b000, 0b010},
+	'-': {0b000, 0b000, 0b111, 0b000, 0b000},
+	'@': {0b010, 0b101, 0b111, 0b100, 0b011},
+}
+
+/*
+	Renders text with the built-in bitmap font into a new RGBA image.
+	Each font pixel is drawn as <scale> x <scale> block of provided color, background is fully transparent.
+	Characters are separated by one blank font pixel.
+*/
+func renderText(text string, scale int, color [4]uint8) *image.RGBA {
+	scale = max(scale, 1)
+	runes := []rune(text)
+	char_count := len(runes)
+
+	width := max(char_count * (glyph_width + 1) - 1, 0) * scale
+	height := glyph_height * scale
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for char_id, char := range runes {
+		glyph := font_glyphs[unicode.ToUpper(char)]
+		base_x := char_id * (glyph_width + 1) * scale
+
+		for row := 0; row < glyph_height; row++ {
+			for column := 0; column < glyph_width; column++ {
+				var bit_set bool = glyph[row] & (1 << (glyph_width - 1 - column)) != 0
+				if !bit_set {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						flat_id := result.PixOffset(base_x + column * scale + dx, row * scale + dy)
+						copy(result.Pix[flat_id: flat_id + 4], color[:])
+					}
+				}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+import (
+	"pixel_restoration/evaluation"
+	"pixel_restoration/restore"
+)
+
+/*
+	Writes report to a file using provided writer function, empty path means the output is not requested
+*/
+func writeReportFile[R any](path string, report R, write func(io.Writer, R) error) error {
+	if path == "" {
+		return nil
+	}
+	outfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return errors.Join(write(outfile, report), outfile.Close())
+}
+
+func commandEvaluate(args []string) int {
+	flags := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	options := restore.GetBaseOptions()
+	apply_pipeline_flags := addPipelineFlags(flags, &options)
+	tolerance := evaluation.GetBaseTolerance()
+	flags.Float64Var(&tolerance.Pixel, "pixel-tolerance", tolerance.Pixel, "allowed difference of guessed pixel size")
+	flags.Float64Var(&tolerance.Grid, "grid-tolerance", tolerance.Grid, "allowed difference of guessed grid width")
+	json_path := flags.String("json", "", "write machine readable JSON report to this path")
+	csv_path := flags.String("csv", "", "write per-file CSV report to this path")
+	quiet := flags.Bool("quiet", false, "print only per-category summary")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pixel_restoration evaluate [flags] [test directories]")
+		fmt.Fprintln(flags.Output(), "Default directories:", evaluation.DEFAULT_TEST_DIRECTORIES)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return EXIT_OK
+		}
+		return EXIT_USAGE
+	}
+	if err := apply_pipeline_flags(); err != nil {
+		fmt.Fprintf(os.Stderr, "evaluate: %v\n", err)
+		return EXIT_USAGE
+	}
+
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = evaluation.DEFAULT_TEST_DIRECTORIES
+	}
+
+	report, err := evaluation.EvaluateDirectories(dirs, options, tolerance)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "evaluate: cannot read test directory: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	if *quiet {
+		report_summary := report
+		report_summary.Files = nil
+		evaluation.PrintReport(os.Stdout, report_summary)
+	} else {
+		evaluation.PrintReport(os.Stdout, report)
+	}
+
+	if err := writeReportFile(*json_path, report, evaluation.WriteJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "evaluate: cannot write JSON report: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	if err := writeReportFile(*csv_path, report, evaluation.WriteCSV); err != nil {
+		fmt.Fprintf(os.Stderr, "evaluate: cannot write CSV report: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	return EXIT_OK
+}
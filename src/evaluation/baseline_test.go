@@ -0,0 +1,121 @@
+package evaluation
+
+import (
+	"flag"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/images"
+	"pixel_restoration/restore"
+)
+
+const BASELINE_PATH string = "testdata/baseline.json"
+
+var update_baseline = flag.Bool("update", false, "rewrite detection baseline with results of the current run")
+
+/*
+	Runs detection on the whole test corpus and compares the output with checked-in baseline.
+	After an intended change of detection results, regenerate the baseline with:
+		go test ./evaluation -run TestDetectionBaseline -update
+*/
+func TestDetectionBaseline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("corpus run skipped in short mode")
+	}
+
+	// test runs from the package directory, default directories are relative to src
+	dirs := make([]string, len(DEFAULT_TEST_DIRECTORIES))
+	for i, dir := range DEFAULT_TEST_DIRECTORIES {
+		dirs[i] = filepath.Join("..", dir)
+	}
+
+	actual, err := SnapshotDirectories(dirs, restore.GetBaseOptions())
+	if err != nil {
+		t.Fatalf("cannot read test directories: %v", err)
+	}
+
+	if *update_baseline {
+		if err := SaveBaseline(BASELINE_PATH, actual); err != nil {
+			t.Fatalf("cannot save baseline: %v", err)
+		}
+		t.Logf("baseline updated with %d images", len(actual.Images))
+		return
+	}
+
+	expected, err := LoadBaseline(BASELINE_PATH)
+	if err != nil {
+		t.Fatalf("cannot load baseline (run with -update to create it): %v", err)
+	}
+
+	diffs := DiffBaselines(expected, actual)
+	for _, diff := range diffs {
+		t.Error(diff)
+	}
+	if len(diffs) > 0 {
+		t.Logf("%d of %d images changed, run with -update if the change is intended", len(diffs), len(expected.Images))
+	}
+}
+
+/*
+	Runs detection with -crop on every clean image whose art reaches all four image borders (no border line is uniform)
+	and checks that the whole image is kept as art, so non-periodic border cropping never cuts into flat art.
+*/
+func TestCropKeepsCleanArt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("corpus run skipped in short mode")
+	}
+
+	dir := filepath.Join("..", DEFAULT_TEST_DIRECTORIES[0])
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read clean test directory: %v", err)
+	}
+	options := restore.GetBaseOptions()
+	options.Crop.Enabled = true
+
+	checked := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if _, err := ParseTestFilename(path); err != nil || entry.IsDir() {
+			continue
+		}
+		img, err := images.RGBALoadFromFile(path)
+		if err != nil || hasUniformBorderLine(img, options.Crop.Tolerance) {
+			continue
+		}
+		checked += 1
+
+		result, err := restore.Restore(img, options)
+		if err != nil {
+			t.Errorf("%s: %v", entry.Name(), err)
+			continue
+		}
+		if result.ArtBounds != img.Rect {
+			t.Errorf("%s: art reaches the image border, but -crop kept only %v of %v", entry.Name(), result.ArtBounds, img.Rect)
+		}
+	}
+	t.Logf("%d clean images with art reaching the border checked", checked)
+}
+
+/*
+	Returns true if the first or last row or column of the image is uniform within tolerance
+*/
+func hasUniformBorderLine(img *image.RGBA, tolerance uint8) bool {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	line_is_uniform := func(x, y, step_x, step_y, length int) bool {
+		first := common.PixelColor(img, x, y)
+		for i := 1; i < length; i++ {
+			if !common.ColorsClose(first, common.PixelColor(img, x + i * step_x, y + i * step_y), tolerance) {
+				return false
+			}
+		}
+		return true
+	}
+	return line_is_uniform(0, 0, 1, 0, width) || line_is_uniform(0, height - 1, 1, 0, width) ||
+		line_is_uniform(0, 0, 0, 1, height) || line_is_uniform(width - 1, 0, 0, 1, height)
+}
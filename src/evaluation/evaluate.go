@@ -0,0 +1,242 @@
+package evaluation
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+import (
+	"pixel_restoration/images"
+	"pixel_restoration/restore"
+	"pixel_restoration/types"
+)
+
+/*
+	Default directories of the test sets with ground truth encoded in file names,
+	relative to the src directory of the repository
+*/
+var DEFAULT_TEST_DIRECTORIES = []string{
+	"../images/test_set_pixelarts_clean",
+	"../images/test_set_pixelarts_grided",
+	"../images/test_set_pixelarts_paper",
+}
+
+/*
+	Pixel: float64
+		Largest allowed difference between guessed and expected pixel size
+	Grid: float64
+		Largest allowed difference between guessed and expected gridline width
+
+	A guess also passes if the expected value lies within its bounds,
+	which covers guesses like [0,1] gridline entry that has a mean of 0.
+*/
+type Tolerance struct {
+	Pixel float64
+	Grid float64
+}
+
+func GetBaseTolerance() Tolerance {
+	return Tolerance{
+		Pixel: 1.0,
+		Grid: 0.5,
+	}
+}
+
+/*
+	FileResult holds evaluation outcome of a single test image.
+	Rows and Cols guesses describe X and Y axis respectively, see restore.Result.
+	ArtBounds holds min X, min Y, max X and max Y of the part of the image the grid was detected in.
+	Error is not empty if the file could not be loaded or detection failed, in that case Pass is false.
+*/
+type FileResult struct {
+	Path string `json:"path"`
+	Category string `json:"category"`
+	Expected Expectation `json:"expected"`
+
+	RowsPixel types.IntervalRangeEntry `json:"rows_pixel"`
+	RowsGrid types.IntervalRangeEntry `json:"rows_grid"`
+	ColsPixel types.IntervalRangeEntry `json:"cols_pixel"`
+	ColsGrid types.IntervalRangeEntry `json:"cols_grid"`
+
+	PixelPass bool `json:"pixel_pass"`
+	GridPass bool `json:"grid_pass"`
+	Pass bool `json:"pass"`
+	Strategy string `json:"strategy,omitempty"`
+	Confidence float64 `json:"confidence"`
+	ArtBounds [4]int `json:"art_bounds"`
+
+	Error string `json:"error,omitempty"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+/*
+	CategorySummary holds accuracy of all files belonging to one category (category and variant of the file name)
+*/
+type CategorySummary struct {
+	Category string `json:"category"`
+	Total int `json:"total"`
+	Passed int `json:"passed"`
+	PixelPassed int `json:"pixel_passed"`
+	GridPassed int `json:"grid_passed"`
+	Errors int `json:"errors"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+/*
+	Report holds results of all evaluated files in the order they were evaluated,
+	per-category summaries sorted by category name and a summary of all files together.
+	Strategies counts how many files were restored by each pipeline strategy (see restore.StrategyName).
+	Skipped holds files that don't follow test set naming convention.
+*/
+type Report struct {
+	Files []FileResult `json:"files"`
+	Categories []CategorySummary `json:"categories"`
+	Overall CategorySummary `json:"overall"`
+	Strategies map[string]int `json:"strategies"`
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+/*
+	EvaluateDirectories runs restoration pipeline on every test image in provided directories
+	and compares detection results with ground truth encoded in file names.
+	Files in each directory are evaluated in lexical order.
+	Returns an error only if a directory cannot be read.
+*/
+func EvaluateDirectories(dirs []string, options restore.Options, tolerance Tolerance) (Report, error) {
+	var report Report
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return report, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			expectation, err := ParseTestFilename(path)
+			if err != nil {
+				report.Skipped = append(report.Skipped, path)
+				continue
+			}
+			report.Files = append(report.Files, EvaluateFile(path, expectation, options, tolerance))
+		}
+	}
+
+	report.Categories, report.Overall = summarizeResults(report.Files)
+	report.Strategies = countStrategies(report.Files)
+	return report, nil
+}
+
+/*
+	EvaluateFile runs restoration pipeline on a single image and compares results with the expectation.
+	Errors of the pipeline (including its recovered panics, see restore.Restore) are reported as errors of the file,
+	so that one broken image doesn't stop evaluation of the whole corpus.
+*/
+func EvaluateFile(path string, expectation Expectation, options restore.Options, tolerance Tolerance) (file_result FileResult) {
+	file_result.Path = path
+	file_result.Category = groupName(expectation)
+	file_result.Expected = expectation
+
+	start := time.Now()
+	defer func() {
+		file_result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	img, err := images.RGBALoadFromFile(path)
+	if err != nil {
+		file_result.Error = err.Error()
+		return file_result
+	}
+
+	result, err := restore.Restore(img, options)
+	if err != nil {
+		file_result.Error = err.Error()
+		return file_result
+	}
+
+	file_result.Strategy = restore.StrategyName(result.Strategy)
+	file_result.Confidence = result.Confidence.Score
+	file_result.ArtBounds = [4]int{result.ArtBounds.Min.X, result.ArtBounds.Min.Y, result.ArtBounds.Max.X, result.ArtBounds.Max.Y}
+	file_result.RowsPixel, file_result.RowsGrid = result.Rows.PixelGuess, result.Rows.GridGuess
+	file_result.ColsPixel, file_result.ColsGrid = result.Cols.PixelGuess, result.Cols.GridGuess
+
+	file_result.PixelPass = GuessMatches(file_result.RowsPixel, expectation.Pixel, tolerance.Pixel) &&
+		GuessMatches(file_result.ColsPixel, expectation.Pixel, tolerance.Pixel)
+	file_result.GridPass = GuessMatches(file_result.RowsGrid, expectation.Grid, tolerance.Grid) &&
+		GuessMatches(file_result.ColsGrid, expectation.Grid, tolerance.Grid)
+	file_result.Pass = file_result.PixelPass && file_result.GridPass
+
+	return file_result
+}
+
+/*
+	Returns true if guessed mean is within tolerance of expected value, or if expected value lies within guessed bounds
+*/
+func GuessMatches(guess types.IntervalRangeEntry, expected float64, tolerance float64) bool {
+	if math.Abs(guess.Mean - expected) <= tolerance {
+		return true
+	}
+	return float64(guess.Bounds[0]) <= expected && expected <= float64(guess.Bounds[1])
+}
+
+/*
+	Groups file results by category and calculates per-category and overall accuracy
+*/
+func summarizeResults(files []FileResult) ([]CategorySummary, CategorySummary) {
+	by_category := make(map[string]*CategorySummary)
+	overall := CategorySummary{Category: "ALL"}
+
+	for _, file := range files {
+		summary, exists := by_category[file.Category]
+		if !exists {
+			summary = &CategorySummary{Category: file.Category}
+			by_category[file.Category] = summary
+		}
+		addToSummary(summary, file)
+		addToSummary(&overall, file)
+	}
+
+	categories := make([]CategorySummary, 0, len(by_category))
+	for _, summary := range by_category {
+		categories = append(categories, *summary)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Category < categories[j].Category
+	})
+
+	return categories, overall
+}
+
+/*
+	Counts files restored by each strategy, files with errors are not counted
+*/
+func countStrategies(files []FileResult) map[string]int {
+	counts := make(map[string]int)
+	for _, file := range files {
+		if file.Strategy != "" {
+			counts[file.Strategy] += 1
+		}
+	}
+	return counts
+}
+
+func addToSummary(summary *CategorySummary, file FileResult) {
+	summary.Total += 1
+	if file.Pass {
+		summary.Passed += 1
+	}
+	if file.PixelPass {
+		summary.PixelPassed += 1
+	}
+	if file.GridPass {
+		summary.GridPassed += 1
+	}
+	if file.Error != "" {
+		summary.Errors += 1
+	}
+	summary.Accuracy = float64(summary.Passed) / float64(summary.Total)
+}
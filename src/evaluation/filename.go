@@ -0,0 +1,119 @@
+package evaluation
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+	Expectation holds the ground truth encoded in a test set file name.
+
+	Category:
+		First token of the file name, for example GRIDED, CLEAN, TILED or PAPER
+	Variant:
+		Optional non-numeric tokens between the category and the numbers, for example DOUBLE or GRADIENT
+	Pixel:
+		Expected pixel size, may be fractional (10.5 means pixels alternate between 10 and 11)
+	Grid:
+		Expected gridline width, 0 for gridless images
+	Tile:
+		Expected tile period in art pixels for TILED images, 0 otherwise
+	Name:
+		Remaining part of the file name without extension
+*/
+type Expectation struct {
+	Category string `json:"category"`
+	Variant string `json:"variant,omitempty"`
+	Pixel float64 `json:"pixel"`
+	Grid float64 `json:"grid"`
+	Tile int `json:"tile,omitempty"`
+	Name string `json:"name"`
+}
+
+/*
+	Describes which numbers follow a category token in the file name, in order of appearance.
+	Supported conventions:
+		GRIDED_<grid>_<pixel>_name
+		PADDED_<grid>_<pixel>_name
+		TILED_<grid>_<pixel>_<tile>_name
+		CLEAN_<pixel>_name
+		PAPER_<pixel>_name
+		STITCH_<pixel>_name
+*/
+var category_number_fields = map[string][]string{
+	"GRIDED": {"grid", "pixel"},
+	"PADDED": {"grid", "pixel"},
+	"TILED":  {"grid", "pixel", "tile"},
+	"CLEAN":  {"pixel"},
+	"PAPER":  {"pixel"},
+	"STITCH": {"pixel"},
+}
+
+/*
+	ParseTestFilename extracts expected detection results from file name of a test set image.
+	Directory part of the path is ignored.
+	Returns an error for files that don't follow any known naming convention (for example BAD_ cases).
+*/
+func ParseTestFilename(path string) (Expectation, error) {
+	base := filepath.Base(path)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	tokens := strings.Split(base, "_")
+
+	var expectation Expectation
+	expectation.Category = tokens[0]
+	fields, known := category_number_fields[expectation.Category]
+	if !known {
+		return expectation, fmt.Errorf("unknown test file category in %q", base)
+	}
+
+	// collecting variant tokens, they are all non-numeric tokens directly after category
+	token_id := 1
+	variants := []string{}
+	for token_id < len(tokens) && !isNumericToken(tokens[token_id]) {
+		variants = append(variants, tokens[token_id])
+		token_id += 1
+	}
+	expectation.Variant = strings.Join(variants, "_")
+
+	if len(tokens) - token_id < len(fields) {
+		return expectation, fmt.Errorf("expected %d numbers after category in %q", len(fields), base)
+	}
+
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(tokens[token_id], 64)
+		if err != nil {
+			return expectation, fmt.Errorf("invalid %s value in %q: %w", field, base, err)
+		}
+		switch field {
+		case "grid":
+			expectation.Grid = value
+		case "pixel":
+			expectation.Pixel = value
+		case "tile":
+			expectation.Tile = int(value)
+		}
+		token_id += 1
+	}
+
+	expectation.Name = strings.Join(tokens[token_id:], "_")
+	return expectation, nil
+}
+
+/*
+	Returns true if token starts with a digit, which is how numbers are distinguished from words in file names
+*/
+func isNumericToken(token string) bool {
+	return len(token) > 0 && token[0] >= '0' && token[0] <= '9'
+}
+
+/*
+	Returns category name used for grouping results, category and variant joined by underscore
+*/
+func groupName(expectation Expectation) string {
+	if expectation.Variant == "" {
+		return expectation.Category
+	}
+	return expectation.Category + "_" + expectation.Variant
+}
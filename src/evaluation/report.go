@@ -0,0 +1,111 @@
+package evaluation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+/*
+	Prints human readable per-file pass/fail lines followed by per-category accuracy table
+*/
+func PrintReport(writer io.Writer, report Report) {
+	for _, file := range report.Files {
+		status := "PASS"
+		if !file.Pass {
+			status = "FAIL"
+		}
+		if file.Error != "" {
+			fmt.Fprintf(writer, "%s  %s\n      error: %s\n", status, file.Path, file.Error)
+			continue
+		}
+		fmt.Fprintf(writer, "%s  %s  (confidence %.2f)\n", status, file.Path, file.Confidence)
+		fmt.Fprintf(writer, "      expected pixel %.1f grid %.1f | rows pixel %.2f grid %.2f | cols pixel %.2f grid %.2f\n",
+			file.Expected.Pixel, file.Expected.Grid,
+			file.RowsPixel.Mean, file.RowsGrid.Mean,
+			file.ColsPixel.Mean, file.ColsGrid.Mean,
+		)
+	}
+
+	fmt.Fprintln(writer)
+	fmt.Fprintf(writer, "%-20s %7s %7s %7s %7s %7s %9s\n", "CATEGORY", "TOTAL", "PASSED", "PIXEL", "GRID", "ERRORS", "ACCURACY")
+	for _, summary := range append(report.Categories, report.Overall) {
+		fmt.Fprintf(writer, "%-20s %7d %7d %7d %7d %7d %8.1f%%\n",
+			summary.Category, summary.Total, summary.Passed,
+			summary.PixelPassed, summary.GridPassed, summary.Errors,
+			summary.Accuracy * 100.0,
+		)
+	}
+	if len(report.Strategies) > 0 {
+		strategy_names := make([]string, 0, len(report.Strategies))
+		for name := range report.Strategies {
+			strategy_names = append(strategy_names, name)
+		}
+		sort.Strings(strategy_names)
+		fmt.Fprint(writer, "\nStrategies:")
+		for _, name := range strategy_names {
+			fmt.Fprintf(writer, " %s %d", name, report.Strategies[name])
+		}
+		fmt.Fprintln(writer)
+	}
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(writer, "\nSkipped %d files not following the naming convention\n", len(report.Skipped))
+	}
+}
+
+/*
+	Writes the whole report as indented JSON
+*/
+func WriteJSON(writer io.Writer, report Report) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+/*
+	Writes one CSV row per evaluated file, with a header row.
+*/
+func WriteCSV(writer io.Writer, report Report) error {
+	csv_writer := csv.NewWriter(writer)
+	header := []string{
+		"path", "category", "expected_pixel", "expected_grid",
+		"rows_pixel", "rows_grid", "cols_pixel", "cols_grid",
+		"pixel_pass", "grid_pass", "pass", "strategy", "confidence", "error", "duration_ms",
+	}
+	if err := csv_writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, file := range report.Files {
+		record := []string{
+			file.Path,
+			file.Category,
+			formatFloat(file.Expected.Pixel),
+			formatFloat(file.Expected.Grid),
+			formatFloat(file.RowsPixel.Mean),
+			formatFloat(file.RowsGrid.Mean),
+			formatFloat(file.ColsPixel.Mean),
+			formatFloat(file.ColsGrid.Mean),
+			strconv.FormatBool(file.PixelPass),
+			strconv.FormatBool(file.GridPass),
+			strconv.FormatBool(file.Pass),
+			file.Strategy,
+			formatFloat(file.Confidence),
+			file.Error,
+			strconv.FormatInt(file.DurationMs, 10),
+		}
+		if err := csv_writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csv_writer.Flush()
+	return csv_writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64)
+}
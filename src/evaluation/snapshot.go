@@ -0,0 +1,265 @@
+package evaluation
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+import (
+	"pixel_restoration/images"
+	"pixel_restoration/restore"
+	"pixel_restoration/types"
+)
+
+/*
+	AxisSnapshot holds full detection output of one axis of an image.
+
+	UnknownCount:
+		Number of INTERVAL_UNKNOWN items in combined list before gridlines.GridlinesFixErrors
+	FixedIntervals, FixedTypes:
+		Intervals and interval types of the fixed combined list.
+		Types are stored as ints, so that JSON holds readable numbers instead of base64 encoded bytes.
+	MajorPeriod:
+		Period in cells of major gridlines (see gridlines.MajorGrid), omitted if the axis has no major grid
+*/
+type AxisSnapshot struct {
+	PixelGuess types.IntervalRangeEntry `json:"pixel_guess"`
+	GridGuess types.IntervalRangeEntry `json:"grid_guess"`
+	UnknownCount int `json:"unknown_count"`
+	FixedIntervals []uint `json:"fixed_intervals"`
+	FixedTypes []int `json:"fixed_types"`
+	MajorPeriod int `json:"major_period,omitempty"`
+}
+
+/*
+	ImageSnapshot holds detection output of both axes of a single image.
+	Name is the image path relative to the images directory, for example test_set_pixelarts_clean/CLEAN_3_swamp.png
+	Strategy is the name of the pipeline strategy that produced the output, see restore.StrategyName.
+*/
+type ImageSnapshot struct {
+	Name string `json:"name"`
+	Strategy string `json:"strategy,omitempty"`
+	Rows AxisSnapshot `json:"rows"`
+	Cols AxisSnapshot `json:"cols"`
+	Error string `json:"error,omitempty"`
+}
+
+/*
+	Baseline is an ordered collection of image snapshots
+*/
+type Baseline struct {
+	Images []ImageSnapshot
+}
+
+/*
+	SnapshotDirectories records detection output of every test image in provided directories.
+	Files that don't follow test set naming convention are skipped, just like in EvaluateDirectories.
+*/
+func SnapshotDirectories(dirs []string, options restore.Options) (Baseline, error) {
+	var baseline Baseline
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return baseline, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if _, err := ParseTestFilename(path); err != nil {
+				continue
+			}
+			baseline.Images = append(baseline.Images, SnapshotFile(path, options))
+		}
+	}
+	return baseline, nil
+}
+
+/*
+	SnapshotFile records detection output of a single image.
+	Load and detection errors (including recovered panics of the pipeline, see restore.Restore)
+	are stored in the Error field of the snapshot.
+*/
+func SnapshotFile(path string, options restore.Options) (snapshot ImageSnapshot) {
+	snapshot.Name = filepath.ToSlash(filepath.Join(filepath.Base(filepath.Dir(path)), filepath.Base(path)))
+	img, err := images.RGBALoadFromFile(path)
+	if err != nil {
+		snapshot.Error = err.Error()
+		return snapshot
+	}
+	result, err := restore.Restore(img, options)
+	if err != nil {
+		snapshot.Error = err.Error()
+		return snapshot
+	}
+
+	snapshot.Strategy = restore.StrategyName(result.Strategy)
+	snapshot.Rows = makeAxisSnapshot(result.Rows)
+	snapshot.Cols = makeAxisSnapshot(result.Cols)
+	return snapshot
+}
+
+func makeAxisSnapshot(axis restore.AxisResult) AxisSnapshot {
+	snapshot := AxisSnapshot{
+		PixelGuess: axis.PixelGuess,
+		GridGuess: axis.GridGuess,
+		FixedIntervals: axis.Fixed.Intervals,
+		FixedTypes: make([]int, len(axis.Fixed.IntervalTypes)),
+		MajorPeriod: axis.Major.Period,
+	}
+	for i, interval_type := range axis.Fixed.IntervalTypes {
+		snapshot.FixedTypes[i] = int(interval_type)
+	}
+	for _, interval_type := range axis.Combined.IntervalTypes {
+		if interval_type == types.INTERVAL_UNKNOWN {
+			snapshot.UnknownCount += 1
+		}
+	}
+	return snapshot
+}
+
+/*
+	Writes baseline to a file as a JSON array with exactly one image per line,
+	so that version control diffs of the baseline file stay per-image.
+*/
+func SaveBaseline(path string, baseline Baseline) error {
+	outfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(outfile)
+	writer.WriteString("[\n")
+	for i, snapshot := range baseline.Images {
+		line, err := json.Marshal(snapshot)
+		if err != nil {
+			return errors.Join(err, outfile.Close())
+		}
+		writer.Write(line)
+		if i != len(baseline.Images) - 1 {
+			writer.WriteString(",")
+		}
+		writer.WriteString("\n")
+	}
+	writer.WriteString("]\n")
+	// a failed close can leave the baseline truncated, so its error is reported like a failed write
+	return errors.Join(writer.Flush(), outfile.Close())
+}
+
+func LoadBaseline(path string) (Baseline, error) {
+	var baseline Baseline
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return baseline, err
+	}
+	err = json.Unmarshal(data, &baseline.Images)
+	return baseline, err
+}
+
+/*
+	DiffBaselines compares two baselines image by image.
+	Returns one human readable multi-line entry per image whose snapshot differs,
+	including images present in only one of the baselines. Empty result means baselines are identical.
+*/
+func DiffBaselines(expected, actual Baseline) []string {
+	actual_by_name := make(map[string]ImageSnapshot, len(actual.Images))
+	for _, snapshot := range actual.Images {
+		actual_by_name[snapshot.Name] = snapshot
+	}
+	expected_names := make(map[string]bool, len(expected.Images))
+
+	diffs := []string{}
+	for _, expected_snapshot := range expected.Images {
+		expected_names[expected_snapshot.Name] = true
+		actual_snapshot, exists := actual_by_name[expected_snapshot.Name]
+		if !exists {
+			diffs = append(diffs, expected_snapshot.Name + ": missing from current run")
+			continue
+		}
+
+		lines := diffImageSnapshots(expected_snapshot, actual_snapshot)
+		if len(lines) > 0 {
+			diffs = append(diffs, expected_snapshot.Name + ":\n    " + strings.Join(lines, "\n    "))
+		}
+	}
+
+	for _, actual_snapshot := range actual.Images {
+		if !expected_names[actual_snapshot.Name] {
+			diffs = append(diffs, actual_snapshot.Name + ": not present in baseline")
+		}
+	}
+	return diffs
+}
+
+func diffImageSnapshots(expected, actual ImageSnapshot) []string {
+	lines := []string{}
+	if expected.Error != actual.Error {
+		lines = append(lines, fmt.Sprintf("error: %q -> %q", expected.Error, actual.Error))
+	}
+	if expected.Strategy != actual.Strategy {
+		lines = append(lines, fmt.Sprintf("strategy: %q -> %q", expected.Strategy, actual.Strategy))
+	}
+	lines = append(lines, diffAxisSnapshots("rows", expected.Rows, actual.Rows)...)
+	lines = append(lines, diffAxisSnapshots("cols", expected.Cols, actual.Cols)...)
+	return lines
+}
+
+func diffAxisSnapshots(axis_name string, expected, actual AxisSnapshot) []string {
+	lines := []string{}
+	if expected.PixelGuess != actual.PixelGuess {
+		lines = append(lines, fmt.Sprintf("%s pixel guess: %v -> %v", axis_name, expected.PixelGuess, actual.PixelGuess))
+	}
+	if expected.GridGuess != actual.GridGuess {
+		lines = append(lines, fmt.Sprintf("%s grid guess: %v -> %v", axis_name, expected.GridGuess, actual.GridGuess))
+	}
+	if expected.UnknownCount != actual.UnknownCount {
+		lines = append(lines, fmt.Sprintf("%s unknown sections: %d -> %d", axis_name, expected.UnknownCount, actual.UnknownCount))
+	}
+	if expected.MajorPeriod != actual.MajorPeriod {
+		lines = append(lines, fmt.Sprintf("%s major grid period: %d -> %d", axis_name, expected.MajorPeriod, actual.MajorPeriod))
+	}
+
+	first_difference := firstDifferentItem(expected, actual)
+	if first_difference >= 0 {
+		lines = append(lines, fmt.Sprintf(
+			"%s fixed list: %d items -> %d items, first difference at item %d: %s -> %s",
+			axis_name, len(expected.FixedIntervals), len(actual.FixedIntervals), first_difference,
+			describeItem(expected, first_difference), describeItem(actual, first_difference),
+		))
+	}
+	return lines
+}
+
+/*
+	Returns index of first item where fixed lists differ in length or type, or -1 if lists are identical
+*/
+func firstDifferentItem(expected, actual AxisSnapshot) int {
+	shorter := min(len(expected.FixedIntervals), len(actual.FixedIntervals))
+	for i := 0; i < shorter; i++ {
+		if expected.FixedIntervals[i] != actual.FixedIntervals[i] || expected.FixedTypes[i] != actual.FixedTypes[i] {
+			return i
+		}
+	}
+	if len(expected.FixedIntervals) != len(actual.FixedIntervals) {
+		return shorter
+	}
+	return -1
+}
+
+func describeItem(snapshot AxisSnapshot, index int) string {
+	if index >= len(snapshot.FixedIntervals) {
+		return "<none>"
+	}
+	type_names := map[int]string{
+		int(types.INTERVAL_UNKNOWN): "unknown",
+		int(types.INTERVAL_PIXEL): "pixel",
+		int(types.INTERVAL_GRID): "grid",
+	}
+	return fmt.Sprintf("%s(%d)", type_names[snapshot.FixedTypes[index]], snapshot.FixedIntervals[index])
+}
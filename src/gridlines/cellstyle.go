@@ -0,0 +1,329 @@
+package gridlines
+
+import (
+	"math"
+)
+
+/*
+	Defines a set of uint8 constants describing how a single art pixel cell is drawn
+	CELL_FLAT:
+		cells are filled with one colour, edges appear only on cell borders (and on both sides of gridlines)
+	CELL_SHADED:
+		cells are drawn as beveled tiles, beads or bricks, whose highlights and shadows repeat in every cell
+		and add edges next to the cell border
+*/
+const (
+	CELL_FLAT uint8 = iota
+	CELL_SHADED uint8 = iota
+)
+
+/*
+	MinPeriod: float64
+		Shading is not searched for in cells shorter than this many pixels
+	RefineRange: float64
+		Period is refined within (1 +- RefineRange) * <given period>, slightly wrong period smears folded edges
+		of long images over several phases, which makes plain gridlines look like shading bands
+	MinPeakFraction: float64
+		Phases of the period whose folded edge count reaches this fraction of the strongest phase are active
+	MinShadingEdges: float64
+		Minimum average number of selected edges in one occurrence of the border band, 2 are explained by plain gridlines
+	MinInteriorFraction: float64
+		Flat part of the cell without active phases must take at least this fraction of the period
+	MaxInteriorEdgeShare: float64
+		Largest fraction of all folded edges that may fall into the flat part of the cell, periods spanning several cells
+		(for example periods of major grids) have weaker cell borders there
+	MinBandWidth: float64
+		Narrower bands are thin gridlines with blurred sides, which have no flat inside to tell them apart from shading
+	MaxValleyFraction: float64
+		Bands whose folded counts between their two strongest phases drop below this fraction of the weaker one
+		are gridlines with blurred sides, uniform inside of a gridline has no edges while shading changes gradually
+	MinSideRatio: float64
+		Weaker of the two phases must reach this fraction of the stronger one for the band to be a gridline,
+		both sides of a gridline have edges wherever the line is visible while highlights and shadows differ
+
+	Constraints:
+		MinPeriod >= 3
+		0 <= RefineRange < 0.5
+		0 < MinPeakFraction <= 1
+		MinShadingEdges >= 3
+		0 < MinInteriorFraction < 1
+		0 <= MaxInteriorEdgeShare < 1
+		MinBandWidth >= 0
+		0 <= MaxValleyFraction < 1
+		0 <= MinSideRatio <= 1
+*/
+type CellStyleParams struct {
+	MinPeriod float64
+	RefineRange float64
+	MinPeakFraction float64
+	MinShadingEdges float64
+	MinInteriorFraction float64
+	MaxInteriorEdgeShare float64
+	MinBandWidth float64
+	MaxValleyFraction float64
+	MinSideRatio float64
+}
+
+func GetBaseCellStyleParams() CellStyleParams {
+	return CellStyleParams{
+		MinPeriod: 4.0,
+		RefineRange: 0.03,
+		MinPeakFraction: 0.2,
+		MinShadingEdges: 3.0,
+		MinInteriorFraction: 0.3,
+		MaxInteriorEdgeShare: 0.2,
+		MinBandWidth: 3.0,
+		MaxValleyFraction: 0.3,
+		MinSideRatio: 0.5,
+	}
+}
+
+/*
+	Result of DetectCellStyle.
+
+	Style: uint8
+		One of CELL_ constants
+	Period: float64
+		Refined period the edge counts were folded by (pixel size + gridline width)
+	BandStart: float64
+		Position of the first edge of the first border band, bands repeat every Period pixels
+	BandWidth: float64
+		Distance between the first and the last edge of a border band (gridline and shading around it)
+	ShadingEdges: float64
+		Average number of selected edges in one occurrence of the band, including both of its ends
+*/
+type CellStyle struct {
+	Style uint8
+	Period float64
+	BandStart float64
+	BandWidth float64
+	ShadingEdges float64
+}
+
+/*
+	DetectCellStyle recognises shading repeated inside every cell of a regular grid.
+
+	Period is refined first (see foldEdgeCounts), then edge counts are folded by it into round(period) phase bins.
+	Flat cells have one active phase per period, or two with gridlines. Shaded cells (beveled corners, beads, bricks)
+	have a band of several active phases around the cell border and a flat interior. The band is the shortest circular
+	range of phases holding all active ones, the largest range without active phases is the interior of the cell.
+	Selected edges (see contrast.SelectMostFrequent) are then grouped by band occurrences, shading adds edges
+	between both sides of the band, while blurred sides of gridlines jitter between phases without adding edges.
+	Returns style CELL_FLAT if the period is too short, the band is too narrow, looks like a plain gridline
+	with blurred sides or has too few edges per occurrence.
+*/
+func DetectCellStyle(edge_counts []uint, edges []int, period float64, params CellStyleParams) CellStyle {
+	flat := CellStyle{Style: CELL_FLAT, Period: period}
+	if period < params.MinPeriod || float64(len(edge_counts)) < 2.0 * period {
+		return flat
+	}
+
+	period, folded := foldEdgeCounts(edge_counts, period, params.RefineRange)
+	flat.Period = period
+	bin_count := len(folded)
+	var strongest float64 = 0
+	for _, value := range folded {
+		strongest = max(strongest, value)
+	}
+	if strongest == 0 {
+		return flat
+	}
+
+	active := make([]bool, bin_count)
+	for bin, value := range folded {
+		active[bin] = value >= params.MinPeakFraction * strongest
+	}
+
+	// largest circular run of inactive bins, the band starts right after it
+	gap_start, gap_length := 0, 0
+	for start := 0; start < bin_count; start++ {
+		if active[start] || !active[(start + bin_count - 1) % bin_count] {
+			continue
+		}
+		length := 0
+		for length < bin_count && !active[(start + length) % bin_count] {
+			length += 1
+		}
+		if length > gap_length {
+			gap_start, gap_length = start, length
+		}
+	}
+
+	var interior_edges, total_edges float64 = 0, 0
+	for _, value := range folded {
+		total_edges += value
+	}
+	for i := 0; i < gap_length; i++ {
+		interior_edges += folded[(gap_start + i) % bin_count]
+	}
+	if float64(gap_length) < params.MinInteriorFraction * float64(bin_count) || interior_edges > params.MaxInteriorEdgeShare * total_edges {
+		return flat
+	}
+
+	band_start_bin := (gap_start + gap_length) % bin_count
+	band_width := float64(bin_count - gap_length - 1) * period / float64(bin_count)
+	if band_width < params.MinBandWidth {
+		return flat
+	}
+	band := make([]float64, bin_count - gap_length)
+	for i := range band {
+		band[i] = folded[(band_start_bin + i) % bin_count]
+	}
+	if isGridlineBand(band, params.MaxValleyFraction, params.MinSideRatio) {
+		return flat
+	}
+
+	style := CellStyle{
+		Style: CELL_SHADED,
+		Period: period,
+		BandStart: float64(band_start_bin) * period / float64(bin_count),
+		BandWidth: band_width,
+	}
+	band_edges, band_count := 0, 0
+	for _, run := range bandRuns(edges, style) {
+		if run[2] == 1 {
+			band_edges += run[1] - run[0] + 1
+			band_count += 1
+		}
+	}
+	if band_count == 0 || float64(band_edges) / float64(band_count) < params.MinShadingEdges {
+		return flat
+	}
+	style.ShadingEdges = float64(band_edges) / float64(band_count)
+	return style
+}
+
+/*
+	Returns true if the two strongest non-adjacent phases of a band are balanced (see CellStyleParams.MinSideRatio)
+	and folded counts between them drop below max_valley_fraction of the weaker one.
+	Both sides of a gridline are the strongest edges of its band and there are no edges between them.
+*/
+func isGridlineBand(band []float64, max_valley_fraction, min_side_ratio float64) bool {
+	strongest := 0
+	for i, value := range band {
+		if value > band[strongest] {
+			strongest = i
+		}
+	}
+	second := -1
+	for i, value := range band {
+		if (i < strongest - 1 || i > strongest + 1) && (second < 0 || value > band[second]) {
+			second = i
+		}
+	}
+	if second < 0 || band[second] < min_side_ratio * band[strongest] {
+		return false
+	}
+
+	valley := band[strongest]
+	for i := min(strongest, second) + 1; i < max(strongest, second); i++ {
+		valley = min(valley, band[i])
+	}
+	return valley < max_valley_fraction * band[second]
+}
+
+/*
+	Returns sorted edge positions with shading edges of CELL_SHADED style removed.
+	Consecutive edges that fall into the same occurrence of the border band (with 1 pixel tolerance on both sides)
+	are replaced by a single edge in the middle of them, so cells span from the middle of one band to the next one
+	and shading doesn't split them into short intervals. Half of the band is left on each side of the cell,
+	see CellStyleMargin. Edges outside of bands and edges of CELL_FLAT style are kept as they are.
+*/
+func FilterShadingEdges(edges []int, style CellStyle) []int {
+	if style.Style != CELL_SHADED || len(edges) == 0 {
+		return edges
+	}
+
+	filtered := make([]int, 0, len(edges))
+	for _, run := range bandRuns(edges, style) {
+		filtered = append(filtered, (edges[run[0]] + edges[run[1]] + 1) / 2)
+	}
+	return filtered
+}
+
+/*
+	Splits sorted edges into runs, consecutive edges that fall into the same occurrence of the border band
+	(with 1 pixel tolerance on both sides) form one run and every edge outside of bands forms a run of its own.
+	Returns [first index, last index, 1 if the run lies in a band else 0] of every run.
+*/
+func bandRuns(edges []int, style CellStyle) [][3]int {
+	// returns index of the band occurrence the edge belongs to, or false if it lies in the flat interior
+	band_of := func(edge int) (int, bool) {
+		shifted := float64(edge) - style.BandStart + 1.0
+		occurrence := math.Floor(shifted / style.Period)
+		offset := shifted - occurrence * style.Period
+		return int(occurrence), offset <= style.BandWidth + 2.0
+	}
+
+	runs := make([][3]int, 0, len(edges))
+	for i := 0; i < len(edges); {
+		band, in_band := band_of(edges[i])
+		if !in_band {
+			runs = append(runs, [3]int{i, i, 0})
+			i += 1
+			continue
+		}
+
+		last := i
+		for last + 1 < len(edges) {
+			next_band, next_in_band := band_of(edges[last + 1])
+			if !next_in_band || next_band != band {
+				break
+			}
+			last += 1
+		}
+		runs = append(runs, [3]int{i, last, 1})
+		i = last + 1
+	}
+	return runs
+}
+
+/*
+	Returns fraction of the cell length covered by half of the border band, the part of the cell on each side
+	that holds shading instead of the cell colour. Returns 0 for CELL_FLAT style.
+*/
+func CellStyleMargin(style CellStyle) float64 {
+	if style.Style != CELL_SHADED || style.Period <= 0 {
+		return 0.0
+	}
+	return style.BandWidth / 2.0 / style.Period
+}
+
+/*
+	Folds edge counts by periods within (1 +- refine_range) * period and returns the period
+	whose folded counts are the most concentrated (highest sum of squared bins) along with them.
+	Periods are stepped the same way strongestFrequency does it, a few steps per width of the DFT response peak.
+*/
+func foldEdgeCounts(edge_counts []uint, period float64, refine_range float64) (float64, []float64) {
+	fold := func(candidate float64) ([]float64, float64) {
+		bin_count := int(math.Round(candidate))
+		folded := make([]float64, bin_count)
+		for position, count := range edge_counts {
+			folded[phaseBin(float64(position), candidate, bin_count)] += float64(count)
+		}
+		var concentration float64 = 0
+		for _, value := range folded {
+			concentration += value * value
+		}
+		return folded, concentration
+	}
+
+	best_period := period
+	best_folded, best_concentration := fold(period)
+	step := max(period * period / (8.0 * float64(len(edge_counts))), 0.001)
+	for candidate := period * (1.0 - refine_range); candidate <= period * (1.0 + refine_range); candidate += step {
+		folded, concentration := fold(candidate)
+		if concentration > best_concentration {
+			best_period, best_folded, best_concentration = candidate, folded, concentration
+		}
+	}
+	return best_period, best_folded
+}
+
+/*
+	Returns index of the phase bin of a position, bins split the period into bin_count equal parts
+*/
+func phaseBin(position, period float64, bin_count int) int {
+	phase := math.Mod(position, period) / period
+	return min(int(phase * float64(bin_count)), bin_count - 1)
+}
@@ -1,19 +1,38 @@
 package gridlines 
 
 import (
-	//"fmt"
+	"fmt"
 	"math"
 	"slices"
 )
+import "pixel_restoration/common"
 import "pixel_restoration/types"
 
 /*
 
 	This function takes combined list with unknowns and fills the unknown gaps based on correct sections.
 	Returns new combined list with no unknown sections.
+	A list of a single item (no interval matched the guesses) is both left and right edge, it is fixed once
+	as the left edge, with sizes taken from the guesses.
+	Returns ErrTooFewEdges if combined list is empty and ErrAxisMismatch if edge sections could not be fitted.
 
 */
-func GridlinesFixErrors(original_combined_list types.CombinedList, pixel_guess, grid_guess types.IntervalRangeEntry) types.CombinedList {	
+func GridlinesFixErrors(
+	original_combined_list types.CombinedList, pixel_guess, grid_guess types.IntervalRangeEntry,
+) (types.CombinedList, error) {
+	if len(original_combined_list.Intervals) == 0 {
+		return types.CombinedList{}, fmt.Errorf("%w: combined list is empty", common.ErrTooFewEdges)
+	}
+
+	if len(original_combined_list.Intervals) == 1 {
+		mean_pixel, mean_grid := calculateItemAverages(original_combined_list, pixel_guess, grid_guess, [][]uint{})
+		only_fixed, err := guessEdgeUnknownSection(original_combined_list.Intervals[0], mean_pixel, mean_grid, true)
+		if err != nil {
+			return types.CombinedList{}, err
+		}
+		return reAssembleCombinedList(original_combined_list, [][]uint{only_fixed}), nil
+	}
+
 	var left_edge_unknown, right_edge_unknown uint
 	var middle_unknowns []uint
 
@@ -31,13 +50,19 @@ func GridlinesFixErrors(original_combined_list types.CombinedList, pixel_guess,
 	// recalculating averages with fixed sections to improve acuraccy for edge guessing
 	mean_pixel, mean_grid = calculateItemAverages(original_combined_list, pixel_guess, grid_guess, middle_fixed)
 
-	left_edge_fixed  := guessEdgeUnknownSection(left_edge_unknown, mean_pixel, mean_grid, true)
-	right_edge_fixed := guessEdgeUnknownSection(right_edge_unknown, mean_pixel, mean_grid, false)
+	left_edge_fixed, err := guessEdgeUnknownSection(left_edge_unknown, mean_pixel, mean_grid, true)
+	if err != nil {
+		return types.CombinedList{}, err
+	}
+	right_edge_fixed, err := guessEdgeUnknownSection(right_edge_unknown, mean_pixel, mean_grid, false)
+	if err != nil {
+		return types.CombinedList{}, err
+	}
 	fixed_sections[0] = left_edge_fixed
 	fixed_sections[len(middle_unknowns) + 1] = right_edge_fixed
 
 	var fixed_combined_list types.CombinedList = reAssembleCombinedList(original_combined_list, fixed_sections)
-	return fixed_combined_list
+	return fixed_combined_list, nil
 
 }
 
@@ -70,7 +95,7 @@ func separateUnknownItems(combined_list types.CombinedList)(uint, uint, []uint){
 		and includes them in average calculation.
 	If no items of certain type found, then sets average to midpoint of pixel guess or grid guess respectively.
 
-	Excludes edges.
+	Excludes edges and grid items outside of grid guess bounds (major gridlines, see MarkMajorGridlines).
 
 */
 func calculateItemAverages(
@@ -84,7 +109,9 @@ func calculateItemAverages(
 	// going over combined list and excluding edge elements
 	length := len(combined_list.Intervals)
 	for i:=1 ; i<length - 1; i++{
-		if combined_list.IntervalTypes[i] == types.INTERVAL_GRID{
+		var within_grid_guess bool = grid_guess.Bounds[0] <= int(combined_list.Intervals[i]) &&
+			int(combined_list.Intervals[i]) <= grid_guess.Bounds[1]
+		if combined_list.IntervalTypes[i] == types.INTERVAL_GRID && within_grid_guess {
 			sum_grid += float64(combined_list.Intervals[i])
 			count_grid += 1
 		}
@@ -179,10 +206,11 @@ func guessMiddleUnknownSection(unknown_length uint, mean_pixel, mean_grid float6
 		first item is of grid type. 
 		Last item may be shorter due to snipping, but cannot be 0-length
 	(item sequence alternates pixel and grid elements)
+	Returns ErrAxisMismatch if the solved sequence is shorter than unknown length.
 
 */
 
-func guessEdgeUnknownSection(unknown_length uint, mean_pixel, mean_grid float64, is_left_edge bool) []uint {
+func guessEdgeUnknownSection(unknown_length uint, mean_pixel, mean_grid float64, is_left_edge bool) ([]uint, error) {
 	// n is estimated count of pixels that unknown_length can contain
     n := int(float64(unknown_length)  / (mean_grid + mean_pixel))
 
@@ -195,14 +223,16 @@ func guessEdgeUnknownSection(unknown_length uint, mean_pixel, mean_grid float64,
     var dummy_sequence_solved []uint = guessMiddleUnknownSection(dummy_sequence_length, mean_pixel, mean_grid)
 
     // trim the solved dummy sequence to desired length 
-    trimSequenceFromRight(&dummy_sequence_solved, unknown_length)
+    if err := trimSequenceFromRight(&dummy_sequence_solved, unknown_length); err != nil {
+    	return nil, err
+    }
  	var trimmed []uint = dummy_sequence_solved
 
     if is_left_edge {
     	slices.Reverse(trimmed)    
     }
 
-    return trimmed
+    return trimmed, nil
 }
 
 /*
@@ -347,7 +377,8 @@ func sliceCountMin(slice []uint) int {
 	Sequence after trim is guaranteed to not end with 0-length interval. 
 
 	Interval sequence is modified in place by a pointer
-	Function will panic if target length is larger than sum of interval lengths in the sequence
+	Returns ErrAxisMismatch and leaves the sequence unchanged if target length is larger than
+	sum of interval lengths in the sequence (or if the sequence is empty)
 
 	Example input:
 		*interval_sequence:     [0,6,1,6,0,5,1]
@@ -356,7 +387,17 @@ func sliceCountMin(slice []uint) int {
 		*interval_sequence:	    [0,6,1,4]
 
 */
-func trimSequenceFromRight(interval_sequence *[]uint, target_item_length uint){
+func trimSequenceFromRight(interval_sequence *[]uint, target_item_length uint) error {
+	var total_length uint = 0
+	for _, interval := range *interval_sequence {
+		total_length += interval
+	}
+	if len(*interval_sequence) == 0 || total_length < target_item_length {
+		return fmt.Errorf(
+			"%w: cannot trim sequence of length %d to length %d", common.ErrAxisMismatch, total_length, target_item_length,
+		)
+	}
+
 	// find last element that will remain
 	var current_index int = 0
 	var accumulated_length uint = (*interval_sequence)[0]
@@ -371,6 +412,7 @@ func trimSequenceFromRight(interval_sequence *[]uint, target_item_length uint){
  	// modify the length of last element to fit the target 
 	var difference uint = accumulated_length - target_item_length
 	(*interval_sequence)[current_index] -= difference
+	return nil
 }
 
 /*
@@ -389,7 +431,7 @@ func reAssembleCombinedList(original_list types.CombinedList, fixed_sections [][
 	var starts_with_grid bool = len(fixed_sections[0]) % 2 == 1
  	var interval_types []uint8 = reAssembleCreateTypes(result_length, starts_with_grid)
 
- 	return types.CombinedList{intervals, interval_types} 
+ 	return types.CombinedList{Intervals: intervals, IntervalTypes: interval_types} 
 }
 
 /*
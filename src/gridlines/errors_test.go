@@ -0,0 +1,47 @@
+package gridlines
+
+import (
+	"testing"
+)
+
+import (
+	"pixel_restoration/types"
+)
+
+/*
+	A combined list of a single unknown item (no interval matched the guesses) is both left and right edge.
+	It used to be fixed once for each edge, which left zero-length pixel items after the fixed sequence.
+*/
+func TestGridlinesFixErrorsSingleItem(t *testing.T) {
+	pixel_guess := types.IntervalRangeEntry{Bounds: [2]int{6, 6}, Count: 1, Mean: 6}
+	grid_guess := types.IntervalRangeEntry{Bounds: [2]int{1, 1}, Count: 1, Mean: 1}
+	for _, guesses := range [2][2]types.IntervalRangeEntry{
+		{pixel_guess, grid_guess},
+		{{Bounds: [2]int{1, 1}, Count: 1, Mean: 1}, {Bounds: [2]int{0, 0}, Count: 1, Mean: 0}},
+	} {
+		combined := types.CombinedList{Intervals: []uint{38}, IntervalTypes: []uint8{types.INTERVAL_UNKNOWN}}
+		fixed, err := GridlinesFixErrors(combined, guesses[0], guesses[1])
+		if err != nil {
+			t.Fatalf("pixel size %v: %v", guesses[0].Mean, err)
+		}
+
+		sum, pixels := 0, 0
+		for i, interval := range fixed.Intervals {
+			sum += int(interval)
+			if fixed.IntervalTypes[i] != types.INTERVAL_PIXEL {
+				continue
+			}
+			pixels += 1
+			if interval == 0 {
+				t.Errorf("pixel size %v: zero-length pixel item at %d of %v", guesses[0].Mean, i, fixed.Intervals)
+				break
+			}
+		}
+		if sum != 38 {
+			t.Errorf("pixel size %v: fixed list sums to %d, expected 38", guesses[0].Mean, sum)
+		}
+		if expected := int(38 / (guesses[0].Mean + guesses[1].Mean)); pixels < expected || pixels > expected + 1 {
+			t.Errorf("pixel size %v: expected %d or %d pixel items, got %d", guesses[0].Mean, expected, expected + 1, pixels)
+		}
+	}
+}
@@ -10,6 +10,25 @@ import (
 
 
 
+/*
+	GuessScores describe how decisive the choice made by GuessGridlineParameters was.
+
+	Winner, RunnerUp: float64
+		Runlength scores of the chosen arrangement and of the best rejected arrangement.
+		Scores roughly count intervals belonging to runs of the arrangement, some of them are multiplied by a bias.
+	IntervalCount: int
+		Number of intervals that were scored (all except first and last)
+	Compared: bool
+		False if the guess was made without comparing any scores (for example only one candidate was found),
+		in that case Winner holds count of the chosen candidate and RunnerUp is 0.
+*/
+type GuessScores struct {
+	Winner float64
+	RunnerUp float64
+	IntervalCount int
+	Compared bool
+}
+
 /*
 	Returns interval range for pixel and gridline if griddy image,
 	otherwise returns interval and zeroed range entry, 
@@ -17,8 +36,16 @@ import (
 */
 
 func GuessGridlineParameters(intervals types.IntervalList) (types.IntervalRangeEntry, types.IntervalRangeEntry) {
+	pixel_guess, grid_guess, _ := GuessGridlineParametersWithScores(intervals)
+	return pixel_guess, grid_guess
+}
+
+/*
+	Same as GuessGridlineParameters, additionally returns runlength scores that decided the guess
+*/
+func GuessGridlineParametersWithScores(intervals types.IntervalList) (types.IntervalRangeEntry, types.IntervalRangeEntry, GuessScores) {
 	if len(intervals.Intervals) < 3 {
-		return types.GetZeroRangeEntry(), types.GetZeroRangeEntry()
+		return types.GetZeroRangeEntry(), types.GetZeroRangeEntry(), GuessScores{}
 	}
 
 	interval_counts := getIntervalCounts(intervals)
@@ -36,24 +63,26 @@ func GuessGridlineParameters(intervals types.IntervalList) (types.IntervalRangeE
 
 
 	var pixel_guess, grid_guess types.IntervalRangeEntry
+	var scores GuessScores
 	if one_involved {
-		pixel_guess, grid_guess = guessParametersWithOne(
+		pixel_guess, grid_guess, scores = guessParametersWithOne(
 			intervals, interval_counts,
 			[2]types.IntervalRangeEntry{candidate1, candidate2},
 		)
 	}else{
-		pixel_guess, grid_guess = guessParametersNoOne(
+		pixel_guess, grid_guess, scores = guessParametersNoOne(
 			intervals, interval_counts,
 			[2]types.IntervalRangeEntry{candidate1, candidate2},
 		)
 	}
+	scores.IntervalCount = len(intervals.Intervals) - 2
 
-	return pixel_guess, grid_guess
+	return pixel_guess, grid_guess, scores
 }
 
 
 func guessParametersWithOne(intervals types.IntervalList, interval_counts []int,
-	 candidates [2]types.IntervalRangeEntry) (types.IntervalRangeEntry, types.IntervalRangeEntry) {
+	 candidates [2]types.IntervalRangeEntry) (types.IntervalRangeEntry, types.IntervalRangeEntry, GuessScores) {
 
 	// Preparing statistics and interval entry values
 	var candidate_smaller, candidate_bigger types.IntervalRangeEntry
@@ -123,9 +152,9 @@ func guessParametersWithOne(intervals types.IntervalList, interval_counts []int,
 		case score_only_1_2:
 			goto ConsiderOnlyOneAndTwo
 		case score_candidate_1_2:
-			return candidate_bigger, entry_1_2
+			return candidate_bigger, entry_1_2, comparedScores(score_candidate_1_2, score_candidate_0_1, score_only_1_2)
 		default: // score_candidate_0_1
-			return candidate_bigger, entry_0_1
+			return candidate_bigger, entry_0_1, comparedScores(score_candidate_0_1, score_candidate_1_2, score_only_1_2)
 
 		}
 
@@ -147,11 +176,11 @@ func guessParametersWithOne(intervals types.IntervalList, interval_counts []int,
 
 		switch highest_score {
 		case score_alternating_1_2:
-			return entry_2_2, entry_1_1
+			return entry_2_2, entry_1_1, comparedScores(float64(score_alternating_1_2), float64(score_1), float64(score_2))
 		case score_2:
-			return entry_2_2, types.GetZeroRangeEntry()
+			return entry_2_2, types.GetZeroRangeEntry(), comparedScores(float64(score_2), float64(score_1), float64(score_alternating_1_2))
 		default: // score_1
-			return entry_1_1, types.GetZeroRangeEntry()
+			return entry_1_1, types.GetZeroRangeEntry(), comparedScores(float64(score_1), float64(score_2), float64(score_alternating_1_2))
 
 		}
 
@@ -160,12 +189,12 @@ func guessParametersWithOne(intervals types.IntervalList, interval_counts []int,
 }
 
 func guessParametersNoOne(intervals types.IntervalList, inteval_counts []int, 
-	candidates [2]types.IntervalRangeEntry) (types.IntervalRangeEntry, types.IntervalRangeEntry){
+	candidates [2]types.IntervalRangeEntry) (types.IntervalRangeEntry, types.IntervalRangeEntry, GuessScores){
 
 	// if no intervals were left for calculatinng second candidate, then candidate 1 is assumed to be pixel size
 	var second_empty bool = candidates[1].Count == 0
 	if second_empty {
-		return candidates[0] , types.GetZeroRangeEntry()
+		return candidates[0] , types.GetZeroRangeEntry(), uncomparedScores(candidates[0])
 	}
 
 	var candidate_smaller, candidate_bigger types.IntervalRangeEntry
@@ -186,7 +215,7 @@ func guessParametersNoOne(intervals types.IntervalList, inteval_counts []int,
 		// If arrangement of larger objects suggests gridline mismatch, choose smaller item as pixel
 		var properly_aligned bool = isDoubleSizedIntervalAligned(intervals, candidate_bigger)
 		if ! properly_aligned {
-			return candidate_smaller, types.GetZeroRangeEntry()
+			return candidate_smaller, types.GetZeroRangeEntry(), uncomparedScores(candidate_smaller)
 		}
 
 
@@ -194,11 +223,11 @@ func guessParametersNoOne(intervals types.IntervalList, inteval_counts []int,
 		bigger_score := singleCandidateRunlengthScore(intervals, candidate_bigger, 1)
 		smaller_score := singleCandidateRunlengthScore(intervals, candidate_smaller, 1)
 		if bigger_score > smaller_score {
-			return candidate_bigger, types.GetZeroRangeEntry()
+			return candidate_bigger, types.GetZeroRangeEntry(), comparedScores(float64(bigger_score), float64(smaller_score))
 		}else if smaller_score > bigger_score {
-			return candidate_smaller, types.GetZeroRangeEntry()
+			return candidate_smaller, types.GetZeroRangeEntry(), comparedScores(float64(smaller_score), float64(bigger_score))
 		}else{ // equal scores, choose 1st candidate
-			return candidates[0], types.GetZeroRangeEntry()
+			return candidates[0], types.GetZeroRangeEntry(), comparedScores(float64(bigger_score), float64(smaller_score))
 		}
 	}
 
@@ -213,16 +242,37 @@ func guessParametersNoOne(intervals types.IntervalList, inteval_counts []int,
 	//fmt.Println("Highest Score: ", highest_score)
 	switch highest_score {
 	case score_alternating:
-		return candidate_bigger, candidate_smaller
+		return candidate_bigger, candidate_smaller, comparedScores(float64(score_alternating), float64(score1), float64(score2))
 	case score1:
-		return candidates[0], types.GetZeroRangeEntry()
+		return candidates[0], types.GetZeroRangeEntry(), comparedScores(float64(score1), float64(score2), float64(score_alternating))
 	default: // score2, currently will never execute but might if algorithm for score is modified
-		return candidates[1], types.GetZeroRangeEntry()
+		return candidates[1], types.GetZeroRangeEntry(), comparedScores(float64(score2), float64(score1), float64(score_alternating))
 
 	}
 
 }
 
+/*
+	Makes scores of a guess chosen by comparing scores, runner up is the highest of the rejected scores
+*/
+func comparedScores(winner float64, rejected ...float64) GuessScores {
+	return GuessScores{
+		Winner: winner,
+		RunnerUp: slices.Max(rejected),
+		Compared: true,
+	}
+}
+
+/*
+	Makes scores of a guess chosen without comparing scores
+*/
+func uncomparedScores(chosen types.IntervalRangeEntry) GuessScores {
+	return GuessScores{
+		Winner: float64(chosen.Count),
+		Compared: false,
+	}
+}
+
 /*
 	Makes a lookup where index is a size of interval and value is the count of detected intervals of this size
 	Lookup size is enough to hold up to the largest encountered interval. (so size of largest interval + 1)
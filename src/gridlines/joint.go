@@ -0,0 +1,197 @@
+package gridlines
+
+import (
+	"math"
+)
+
+import (
+	"pixel_restoration/types"
+)
+
+/*
+	Defines a set of uint8 constants used to select how guesses of both axes are coupled by GuessJoint
+	JOINT_INDEPENDENT:
+		axes are guessed independently, guesses are returned unchanged
+	JOINT_FIXED_ASPECT:
+		both axes share one (pixel, grid) hypothesis, pixel size of Y axis is AspectRatio times pixel size of X axis
+		(AspectRatio 1.0 means square cells, which is the usual case for pixel art)
+	JOINT_SHARED_GRID:
+		pixel sizes stay independent (stretched sources), only gridline width is shared by both axes
+*/
+const (
+	JOINT_INDEPENDENT uint8 = iota
+	JOINT_FIXED_ASPECT uint8 = iota
+	JOINT_SHARED_GRID uint8 = iota
+)
+
+var joint_mode_names = [3]string{"independent", "fixed-aspect", "shared-grid"}
+
+/*
+	Returns short name of a JOINT_ constant, used in reports and command line flags
+*/
+func JointModeName(mode uint8) string {
+	if int(mode) >= len(joint_mode_names) {
+		return "unknown"
+	}
+	return joint_mode_names[mode]
+}
+
+/*
+	Returns JOINT_ constant with given name and true, or false if no joint mode has that name
+*/
+func JointModeFromName(name string) (uint8, bool) {
+	for mode, mode_name := range joint_mode_names {
+		if mode_name == name {
+			return uint8(mode), true
+		}
+	}
+	return 0, false
+}
+
+/*
+	Mode: uint8
+		One of JOINT_ constants
+	AspectRatio: float64
+		Expected pixel height divided by pixel width, used by JOINT_FIXED_ASPECT
+
+	Constraints:
+		AspectRatio > 0
+*/
+type JointParams struct {
+	Mode uint8
+	AspectRatio float64
+}
+
+func GetBaseJointParams() JointParams {
+	return JointParams{
+		Mode: JOINT_FIXED_ASPECT,
+		AspectRatio: 1.0,
+	}
+}
+
+/*
+	GuessJoint couples independent guesses of both axes, so that the axis with clearer intervals can correct the other one.
+	Guesses are [pixel, grid] pairs returned by GuessGridlineParameters for interval lists of X (rows) and Y (cols) axis.
+
+	Every hypothesis made by either axis is scored against interval lists of both axes with the same runlength scores
+	GuessGridlineParameters uses, divided by number of scored intervals so that axes of different lengths weigh the same.
+	The hypothesis with the highest sum of both scores wins, ties keep the axis' own guess.
+
+	Returns new guesses of X and Y axis and whether each of them was replaced.
+*/
+func GuessJoint(
+	rows_intervals, cols_intervals types.IntervalList,
+	rows_guess, cols_guess [2]types.IntervalRangeEntry, params JointParams,
+) ([2]types.IntervalRangeEntry, [2]types.IntervalRangeEntry, [2]bool) {
+	rows_new, cols_new, corrected, _ := GuessJointWithScores(rows_intervals, cols_intervals, rows_guess, cols_guess, params)
+	return rows_new, cols_new, corrected
+}
+
+/*
+	Same as GuessJoint, additionally returns scores of the new guess of every replaced axis (zero scores for kept ones).
+	Winner is the runlength score of the new guess on intervals of its own axis, so coverage keeps its meaning,
+	RunnerUp is Winner scaled by <joint score of the discarded hypothesis> / <joint score of the winning one>,
+	so the margin tells how decisive the joint comparison was.
+*/
+func GuessJointWithScores(
+	rows_intervals, cols_intervals types.IntervalList,
+	rows_guess, cols_guess [2]types.IntervalRangeEntry, params JointParams,
+) ([2]types.IntervalRangeEntry, [2]types.IntervalRangeEntry, [2]bool, [2]GuessScores) {
+	unchanged := [2]bool{false, false}
+	var no_scores [2]GuessScores
+	if len(rows_intervals.Intervals) < 3 || len(cols_intervals.Intervals) < 3 {
+		return rows_guess, cols_guess, unchanged, no_scores
+	}
+
+	// scores of the new guess of a replaced axis, winner is always positive since it beat the other hypothesis
+	replaced_scores := func(intervals types.IntervalList, guess [2]types.IntervalRangeEntry, winner, loser float64) GuessScores {
+		interval_count := len(intervals.Intervals) - 2
+		own := hypothesisScore(intervals, guess) * float64(interval_count)
+		return GuessScores{Winner: own, RunnerUp: own * loser / winner, IntervalCount: interval_count, Compared: true}
+	}
+
+	switch params.Mode {
+	case JOINT_FIXED_ASPECT:
+		ratio := params.AspectRatio
+		if ratio <= 0 {
+			ratio = 1.0
+		}
+		// hypotheses are stored as X axis guesses, Y axis pixel size is scaled by the ratio
+		hypotheses := [2][2]types.IntervalRangeEntry{rows_guess, {scaledEntry(cols_guess[0], 1.0 / ratio), cols_guess[1]}}
+		joint_score := func(hypothesis [2]types.IntervalRangeEntry) float64 {
+			cols_hypothesis := [2]types.IntervalRangeEntry{scaledEntry(hypothesis[0], ratio), hypothesis[1]}
+			return hypothesisScore(rows_intervals, hypothesis) + hypothesisScore(cols_intervals, cols_hypothesis)
+		}
+
+		rows_score, cols_score := joint_score(hypotheses[0]), joint_score(hypotheses[1])
+		if rows_score > cols_score {
+			cols_new := [2]types.IntervalRangeEntry{scaledEntry(rows_guess[0], ratio), rows_guess[1]}
+			scores := [2]GuessScores{{}, replaced_scores(cols_intervals, cols_new, rows_score, cols_score)}
+			return rows_guess, cols_new, [2]bool{false, true}, scores
+		} else if cols_score > rows_score {
+			scores := [2]GuessScores{replaced_scores(rows_intervals, hypotheses[1], cols_score, rows_score), {}}
+			return hypotheses[1], cols_guess, [2]bool{true, false}, scores
+		}
+		return rows_guess, cols_guess, unchanged, no_scores
+
+	case JOINT_SHARED_GRID:
+		joint_score := func(grid types.IntervalRangeEntry) float64 {
+			return hypothesisScore(rows_intervals, [2]types.IntervalRangeEntry{rows_guess[0], grid}) +
+				hypothesisScore(cols_intervals, [2]types.IntervalRangeEntry{cols_guess[0], grid})
+		}
+
+		rows_score, cols_score := joint_score(rows_guess[1]), joint_score(cols_guess[1])
+		if rows_score > cols_score && cols_guess[1] != rows_guess[1] {
+			cols_new := [2]types.IntervalRangeEntry{cols_guess[0], rows_guess[1]}
+			scores := [2]GuessScores{{}, replaced_scores(cols_intervals, cols_new, rows_score, cols_score)}
+			return rows_guess, cols_new, [2]bool{false, true}, scores
+		} else if cols_score > rows_score && cols_guess[1] != rows_guess[1] {
+			rows_new := [2]types.IntervalRangeEntry{rows_guess[0], cols_guess[1]}
+			scores := [2]GuessScores{replaced_scores(rows_intervals, rows_new, cols_score, rows_score), {}}
+			return rows_new, cols_guess, [2]bool{true, false}, scores
+		}
+		return rows_guess, cols_guess, unchanged, no_scores
+	}
+
+	return rows_guess, cols_guess, unchanged, no_scores
+}
+
+/*
+	Scores [pixel, grid] hypothesis against interval list with the runlength score matching the hypothesis arrangement,
+	divided by number of scored intervals
+*/
+func hypothesisScore(intervals types.IntervalList, hypothesis [2]types.IntervalRangeEntry) float64 {
+	pixel, grid := hypothesis[0], hypothesis[1]
+	interval_count := len(intervals.Intervals) - 2
+	if interval_count < 2 || pixel.Bounds[1] == 0 {
+		return 0.0
+	}
+
+	var score int
+	switch {
+	case grid.Bounds[1] == 0:
+		score = singleCandidateRunlengthScore(intervals, pixel, 0)
+	case grid.Bounds[0] == 0 && grid.Bounds[1] == 1:
+		score = alternatingCandidatesRunlengthScore_0_1(intervals, pixel)
+	case grid.Bounds[0] == 1 && grid.Bounds[1] == 2:
+		score = alternatingCandidatesRunlengthScore_1_2(intervals, pixel)
+	default:
+		score = alternatingCandidatesRunlengthScore(intervals, hypothesis)
+	}
+	return float64(score) / float64(interval_count)
+}
+
+/*
+	Returns a copy of the entry with mean and bounds multiplied by factor, bounds are widened to whole pixels
+*/
+func scaledEntry(entry types.IntervalRangeEntry, factor float64) types.IntervalRangeEntry {
+	if factor == 1.0 {
+		return entry
+	}
+	entry.Mean *= factor
+	entry.Bounds = [2]int{
+		max(int(math.Floor(float64(entry.Bounds[0]) * factor)), 1),
+		max(int(math.Ceil(float64(entry.Bounds[1]) * factor)), 1),
+	}
+	return entry
+}
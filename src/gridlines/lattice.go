@@ -0,0 +1,171 @@
+package gridlines
+
+import (
+	"math"
+)
+
+import (
+	"pixel_restoration/types"
+)
+
+/*
+	LatticeParams describe fitting of a continuous lattice to detected edges.
+
+	SearchRange: float64
+		Periods within (1 +- SearchRange) * <guessed period> are tried by phase coherence search
+	OutlierFraction: float64
+		Edges further than OutlierFraction * Period from the nearest gridline are ignored by least-squares refinement
+	RefineIterations: int
+		Number of least-squares refinement passes after coherence search
+*/
+type LatticeParams struct {
+	SearchRange float64
+	OutlierFraction float64
+	RefineIterations int
+}
+
+func GetBaseLatticeParams() LatticeParams {
+	return LatticeParams{
+		SearchRange: 0.15,
+		OutlierFraction: 0.25,
+		RefineIterations: 2,
+	}
+}
+
+/*
+	FitLattice fits period and phase of a continuous lattice to sorted edge positions of a single axis.
+	Integer guesses are used only as a starting point, so non-integer pixel sizes (for example 10.5) are recovered.
+
+	1. Phase coherence: for periods around <pixel guess + grid guess> the edges are mapped onto a unit circle
+	   (angle 2pi * edge / period), the period with the longest mean vector wins and its angle gives the phase.
+	   Both edges of a gridline pull the angle towards the gridline centre.
+	2. Least squares: every edge is assigned to the nearest gridline, gridline starts and ends are shifted to the centre
+	   and a line edge = centre + k * period is fitted, which also refines gridline width.
+
+	If fewer than 2 edges are available or guessed period is too small to be fitted,
+	the lattice is built from the guesses only, with Phase 0 and Coherence 0.
+*/
+func FitLattice(edges []int, pixel_guess, grid_guess types.IntervalRangeEntry, params LatticeParams) types.Lattice {
+	grid_width := 0.0
+	if grid_guess.Bounds[1] > 0 {
+		grid_width = grid_guess.Mean
+	}
+	guessed_period := pixel_guess.Mean + grid_width
+	lattice := types.Lattice{Period: max(guessed_period, 1.0), GridWidth: grid_width}
+
+	// integer edges are perfectly coherent with every period 1/n, so periods close to 1 can't be told apart
+	if len(edges) < 2 || guessed_period < 1.5 {
+		return lattice
+	}
+
+	period, centre, coherence := coherentPeriod(edges, guessed_period, params.SearchRange)
+	for i := 0; i < params.RefineIterations; i++ {
+		period, centre, grid_width = refineLattice(edges, period, centre, grid_width, params.OutlierFraction)
+	}
+
+	lattice.Period = period
+	lattice.GridWidth = grid_width
+	lattice.Phase = math.Mod(centre - grid_width / 2.0, period)
+	if lattice.Phase < 0 {
+		lattice.Phase += period
+	}
+	lattice.Coherence = coherence
+	return lattice
+}
+
+/*
+	Returns period with the highest phase coherence of edges within (1 +- search_range) * guessed_period,
+	position of a gridline centre for that period and the coherence itself (0.0 - 1.0).
+*/
+func coherentPeriod(edges []int, guessed_period float64, search_range float64) (float64, float64, float64) {
+	low := max(guessed_period * (1.0 - search_range), 1.5)
+	high := guessed_period * (1.0 + search_range)
+	span := float64(edges[len(edges) - 1] - edges[0])
+
+	// coherence peak is about period^2 / span wide, so a few steps per peak width are enough
+	step := max(guessed_period * guessed_period / (8.0 * max(span, 1.0)), 0.001)
+
+	best_period, best_angle, best_coherence := guessed_period, 0.0, -1.0
+	for period := low; period <= high; period += step {
+		coherence, angle := phaseCoherence(edges, period)
+		if coherence > best_coherence {
+			best_period, best_angle, best_coherence = period, angle, coherence
+		}
+	}
+
+	return best_period, best_angle / (2.0 * math.Pi) * best_period, best_coherence
+}
+
+/*
+	Returns length and angle of the mean unit vector of all edges mapped onto a circle of given period
+*/
+func phaseCoherence(edges []int, period float64) (float64, float64) {
+	var sum_sin, sum_cos float64
+	for _, edge := range edges {
+		angle := 2.0 * math.Pi * float64(edge) / period
+		sum_sin += math.Sin(angle)
+		sum_cos += math.Cos(angle)
+	}
+	length := math.Hypot(sum_sin, sum_cos) / float64(len(edges))
+	return length, math.Atan2(sum_sin, sum_cos)
+}
+
+/*
+	Single least-squares pass, assigns each edge to the nearest gridline of the current lattice and fits
+	centre + k * period to them. Edges left of a gridline centre are treated as gridline starts, the others as ends.
+	Returns unchanged values if fewer than 2 distinct gridlines have inlying edges.
+*/
+func refineLattice(edges []int, period, centre, grid_width float64, outlier_fraction float64) (float64, float64, float64) {
+	var sum_k, sum_x, sum_kk, sum_kx, count float64
+	var sum_start, count_start, sum_end, count_end float64
+	min_k, max_k := math.MaxInt, math.MinInt
+
+	for _, edge := range edges {
+		position := float64(edge)
+		k := math.Round((position - centre) / period)
+		residual := position - (centre + k * period)
+
+		shift := grid_width / 2.0
+		if residual < 0 {
+			shift = -shift
+		}
+		if math.Abs(residual - shift) > outlier_fraction * period {
+			continue
+		}
+		if grid_width > 0 {
+			if residual < 0 {
+				sum_start += residual
+				count_start += 1
+			} else {
+				sum_end += residual
+				count_end += 1
+			}
+		}
+
+		position -= shift
+		sum_k += k
+		sum_x += position
+		sum_kk += k * k
+		sum_kx += k * position
+		count += 1
+		min_k, max_k = min(min_k, int(k)), max(max_k, int(k))
+	}
+
+	if count < 2 || min_k == max_k {
+		return period, centre, grid_width
+	}
+
+	denominator := count * sum_kk - sum_k * sum_k
+	new_period := (count * sum_kx - sum_k * sum_x) / denominator
+	new_centre := (sum_x - new_period * sum_k) / count
+
+	new_grid_width := grid_width
+	if count_start > 0 && count_end > 0 {
+		new_grid_width = sum_end / count_end - sum_start / count_start
+	}
+	// refinement can't move the lattice by more than the outlier limit, otherwise edges were assigned wrongly
+	if math.Abs(new_period - period) > outlier_fraction * period || new_grid_width < 0 || new_grid_width >= new_period {
+		return period, centre, grid_width
+	}
+	return new_period, new_centre, new_grid_width
+}
@@ -0,0 +1,375 @@
+package gridlines
+
+import (
+	"math"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/types"
+)
+
+/*
+	MinCount: int
+		Minimum number of major gridline candidates of the same width required to accept a major grid
+	MinRegularity: float64
+		Minimum fraction of distances between consecutive candidates that are multiples of the detected period
+	MaxWidthFraction: float64
+		Candidates wider than this fraction of the mean pixel size are not considered gridlines
+	ColorTolerance: uint8
+		Gridlines whose colours differ by at most this value in every channel have the same colour, see DetectMajorGridByColor
+	MinLineStrength: float64
+		Cell borders where a smaller fraction of pixels belongs to a gridline have no gridline, see contrast.GridlineColors
+	MinColorPeriod: int
+		Major gridlines found by colour are at least this many cells apart,
+		shorter periods of differently coloured gridlines come from the art or from rounding of fractional gridlines
+	MinColorRegularity: float64
+		Same as MinRegularity for major gridlines found by colour, which are drawn over the whole art and rarely missed
+
+	Constraints:
+		MinCount >= 2
+		0 < MinRegularity <= 1
+		MaxWidthFraction > 0
+		0 < MinLineStrength <= 1
+		MinColorPeriod >= 2
+		0 < MinColorRegularity <= 1
+*/
+type MajorGridParams struct {
+	MinCount int
+	MinRegularity float64
+	MaxWidthFraction float64
+	ColorTolerance uint8
+	MinLineStrength float64
+	MinColorPeriod int
+	MinColorRegularity float64
+}
+
+func GetBaseMajorGridParams() MajorGridParams {
+	return MajorGridParams{
+		MinCount: 3,
+		MinRegularity: 0.6,
+		MaxWidthFraction: 0.5,
+		ColorTolerance: 24,
+		MinLineStrength: 0.25,
+		MinColorPeriod: 4,
+		MinColorRegularity: 0.8,
+	}
+}
+
+/*
+	Describes secondary (major) gridlines, for example a thicker line every 8 cells on top of a regular 1 pixel grid.
+
+	Bounds, Mean:
+		Range and average width of major gridlines in image pixels
+	Period: int
+		Number of cells between consecutive major gridlines, 0 if no major grid was found
+	Count: int
+		Number of major gridline bands found in the combined list
+	Regularity: float64
+		Fraction of distances between consecutive major gridlines that are multiples of Period
+	First: int
+		Position of the first major gridline in image pixels
+*/
+type MajorGrid struct {
+	Bounds [2]int
+	Mean float64
+	Period int
+	Count int
+	Regularity float64
+	First int
+}
+
+/*
+	Looks for a secondary periodic gridline structure in combined list built from pixel and grid guesses.
+
+	Candidates are unknown items placed between two pixel items, at least 2 pixels wider than the grid guess
+	and narrower than MaxWidthFraction of a pixel. Gridlines just one pixel wider are left out, since a fractional
+	gridline width is rounded to both neighbouring widths in a pattern repeating every few cells (1.5 pixel lines
+	alternate 1 and 2 pixels), which looks the same. Such major grids are found only if their colour differs,
+	see DetectMajorGridByColor.
+	The most common candidate width range is chosen with the same interval ranges GuessGridlineParameters uses,
+	and distances between candidates (in cells of pixel + grid size) must be regular.
+	Returns zero MajorGrid (Period 0) if no such structure is found.
+*/
+func DetectMajorGrid(
+	combined types.CombinedList, pixel_guess, grid_guess types.IntervalRangeEntry, params MajorGridParams,
+) MajorGrid {
+	cell_size := pixel_guess.Mean + grid_guess.Mean
+	if pixel_guess.Mean <= 0 || len(combined.Intervals) < 3 {
+		return MajorGrid{}
+	}
+
+	candidates, positions := majorGridCandidates(combined, grid_guess.Bounds[1] + 1, pixel_guess.Mean * params.MaxWidthFraction)
+	if len(candidates) < params.MinCount {
+		return MajorGrid{}
+	}
+
+	// choosing the most common width range of candidates
+	largest := 0
+	for _, i := range candidates {
+		largest = max(largest, int(combined.Intervals[i]))
+	}
+	width_counts := make([]int, largest + 1)
+	for _, i := range candidates {
+		width_counts[combined.Intervals[i]] += 1
+	}
+	width_entry := mostCommonIntervalRange(getIntervalRanges(width_counts))
+	if width_entry.Count < params.MinCount {
+		return MajorGrid{}
+	}
+
+	// distances between consecutive candidates of chosen width, in cells
+	matching := make([]int, 0, len(candidates))
+	for k, i := range candidates {
+		width := int(combined.Intervals[i])
+		if width_entry.Bounds[0] <= width && width <= width_entry.Bounds[1] {
+			matching = append(matching, positions[k])
+		}
+	}
+	cell_distances := make([]int, len(matching) - 1)
+	for k := 1; k < len(matching); k++ {
+		cell_distances[k - 1] = int(math.Round(float64(matching[k] - matching[k - 1]) / cell_size))
+	}
+
+	period, regularity := majorGridPeriod(cell_distances)
+	if period < 2 || regularity < params.MinRegularity {
+		return MajorGrid{}
+	}
+
+	return MajorGrid{
+		Bounds: width_entry.Bounds,
+		Mean: width_entry.Mean,
+		Period: period,
+		Count: width_entry.Count,
+		Regularity: regularity,
+		First: matching[0],
+	}
+}
+
+/*
+	Looks for major gridlines that differ from the regular ones by colour, for example a dark line every 5 cells
+	on top of a grey 1 pixel grid, or a grid drawn only every few cells over an image with a regular grid.
+
+	colors and strengths describe gridline pixels at every position of the axis, see contrast.GridlineColors.
+	At every cell border of the lattice the position with the strongest gridline within 1 pixel is taken,
+	borders are counted from the last gridline found to follow lattices with slightly wrong periods,
+	borders with strength below MinLineStrength have no gridline. Gridlines are grouped by colour (ColorTolerance),
+	the largest group is the regular grid and at least a quarter of the borders must have a gridline.
+	Another group whose distances in cells are regular (MinColorRegularity), at least MinColorPeriod long and that spans
+	at least half of the axis with few missing lines is a major grid, the largest such group wins.
+	Gridlines of semi-transparent grids change colour with the art too, but rarely in such a pattern.
+	On lattices with a fractional period, periods spanning a whole number of pixels are rejected, since borders
+	are rounded to whole pixels the same way every such period (every 2 cells for periods of x.5 pixels)
+	and gridlines rounded differently look differently coloured.
+	Returns zero MajorGrid (Period 0) if no such structure is found.
+*/
+func DetectMajorGridByColor(lattice types.Lattice, colors [][4]uint8, strengths []float64, params MajorGridParams) MajorGrid {
+	if lattice.Coherence == 0 || lattice.Period < 3 || len(colors) != len(strengths) {
+		return MajorGrid{}
+	}
+
+	type gridline struct {
+		border int
+		position int
+		color [4]uint8
+	}
+	lines := make([]gridline, 0, 64)
+	borders := 0
+	// borders are followed from the last gridline found, so a slightly wrong period doesn't drift away from them
+	anchor, anchor_border := lattice.Phase + lattice.GridWidth / 2.0, 0
+	for border := 0; ; border++ {
+		centre := int(math.Round(anchor + float64(border - anchor_border) * lattice.Period))
+		if centre - 1 >= len(strengths) {
+			break
+		}
+		borders += 1
+		best := -1
+		for position := max(centre - 1, 0); position <= min(centre + 1, len(strengths) - 1); position++ {
+			if best < 0 || strengths[position] > strengths[best] {
+				best = position
+			}
+		}
+		if strengths[best] >= params.MinLineStrength {
+			lines = append(lines, gridline{border: border, position: best, color: colors[best]})
+			anchor, anchor_border = float64(best), border
+		}
+	}
+
+	// groups of gridlines of the same colour, each group is compared with the colour of its first line
+	groups := make([][]gridline, 0, 4)
+	for _, line := range lines {
+		found := false
+		for k := range groups {
+			if common.ColorsClose(groups[k][0].color, line.color, params.ColorTolerance) {
+				groups[k] = append(groups[k], line)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []gridline{line})
+		}
+	}
+
+	// the largest group is the regular grid, major gridlines are drawn over the whole art
+	if 4 * len(lines) < borders || len(groups) < 2 {
+		return MajorGrid{}
+	}
+	minor := 0
+	for k := range groups {
+		if len(groups[k]) > len(groups[minor]) {
+			minor = k
+		}
+	}
+	// rounding of cell borders of a fractional lattice repeats every period cells that span a whole number of pixels
+	var fractional bool = math.Abs(lattice.Period - math.Round(lattice.Period)) > 0.1
+	is_rounding := func(period int) bool {
+		length := float64(period) * lattice.Period
+		return fractional && math.Abs(length - math.Round(length)) < 0.05
+	}
+
+	var major MajorGrid
+	var major_group []gridline
+	for k, group := range groups {
+		if k == minor || len(group) < params.MinCount || len(group) <= major.Count {
+			continue
+		}
+		cell_distances := make([]int, len(group) - 1)
+		for j := 1; j < len(group); j++ {
+			cell_distances[j - 1] = group[j].border - group[j - 1].border
+		}
+		period, regularity := majorGridPeriod(cell_distances)
+		span := group[len(group) - 1].border - group[0].border
+		if period < params.MinColorPeriod || is_rounding(period) || regularity < params.MinColorRegularity {
+			continue
+		}
+		// lines of the art are shorter than the axis, miss some of the borders or fall between them
+		var complete bool = float64(len(group) - 1) >= params.MinRegularity * float64(span / period) &&
+			len(group) <= borders / period + 1
+		if 2 * span < borders || !complete {
+			continue
+		}
+		major = MajorGrid{Period: period, Count: len(group), Regularity: regularity}
+		major_group = group
+	}
+	if major.Period == 0 {
+		return MajorGrid{}
+	}
+
+	// width of a gridline is the run of positions around it with gridline pixels of its colour
+	widths := make([]int, len(major_group))
+	for k, line := range major_group {
+		is_line := func(position int) bool {
+			return position >= 0 && position < len(strengths) && strengths[position] >= params.MinLineStrength &&
+				common.ColorsClose(colors[position], line.color, params.ColorTolerance)
+		}
+		start, end := line.position, line.position + 1
+		for is_line(start - 1) {
+			start -= 1
+		}
+		for is_line(end) {
+			end += 1
+		}
+		widths[k] = end - start
+		if k == 0 {
+			major.First = start
+		}
+	}
+	major.Bounds = [2]int{widths[0], widths[0]}
+	total := 0
+	for _, width := range widths {
+		major.Bounds = [2]int{min(major.Bounds[0], width), max(major.Bounds[1], width)}
+		total += width
+	}
+	major.Mean = float64(total) / float64(len(widths))
+	return major
+}
+
+/*
+	Returns a copy of combined list where unknown items matching major grid bounds and placed between two pixel items
+	are marked as INTERVAL_GRID, if they start a multiple of Period cells (of pixel + grid guess size) away from
+	the first major gridline, with 1 cell of tolerance. Distances are counted from the last marked gridline,
+	since every major gridline is wider than a regular one. Other unknown items of that width are left
+	to be fixed as errors. Returns the list unchanged if major grid is empty.
+*/
+func MarkMajorGridlines(
+	combined types.CombinedList, pixel_guess, grid_guess types.IntervalRangeEntry, major MajorGrid,
+) types.CombinedList {
+	cell_size := pixel_guess.Mean + grid_guess.Mean
+	if major.Period == 0 || cell_size <= 0 {
+		return combined
+	}
+
+	marked := types.CombinedList{
+		Intervals: append([]uint{}, combined.Intervals...),
+		IntervalTypes: append([]uint8{}, combined.IntervalTypes...),
+	}
+	candidates, positions := majorGridCandidates(combined, major.Bounds[0] - 1, float64(major.Bounds[1]))
+	anchor := major.First
+	for k, i := range candidates {
+		cells := int(math.Round(float64(positions[k] - anchor) / cell_size))
+		offset := ((cells % major.Period) + major.Period) % major.Period
+		if offset <= 1 || offset >= major.Period - 1 {
+			marked.IntervalTypes[i] = types.INTERVAL_GRID
+			anchor = positions[k]
+		}
+	}
+	return marked
+}
+
+/*
+	Returns indexes and start positions of unknown items surrounded by pixel items,
+	whose length is larger than min_exclusive and at most max_inclusive. First and last item are skipped.
+*/
+func majorGridCandidates(combined types.CombinedList, min_exclusive int, max_inclusive float64) ([]int, []int) {
+	indexes := make([]int, 0, 16)
+	positions := make([]int, 0, 16)
+
+	position := int(combined.Intervals[0])
+	for i := 1; i < len(combined.Intervals) - 1; i++ {
+		length := int(combined.Intervals[i])
+		var surrounded_by_pixels bool = combined.IntervalTypes[i - 1] == types.INTERVAL_PIXEL &&
+			combined.IntervalTypes[i + 1] == types.INTERVAL_PIXEL
+		var fits bool = length > min_exclusive && float64(length) <= max_inclusive
+
+		if combined.IntervalTypes[i] == types.INTERVAL_UNKNOWN && surrounded_by_pixels && fits {
+			indexes = append(indexes, i)
+			positions = append(positions, position)
+		}
+		position += length
+	}
+	return indexes, positions
+}
+
+/*
+	Given distances between consecutive major gridlines in cells, returns the most common distance
+	and fraction of distances that are its multiples (missed lines make some distances multiples of the period).
+	Ties choose the smaller distance.
+*/
+func majorGridPeriod(cell_distances []int) (int, float64) {
+	if len(cell_distances) == 0 {
+		return 0, 0.0
+	}
+
+	counts := make(map[int]int)
+	for _, distance := range cell_distances {
+		counts[distance] += 1
+	}
+	period, best_count := 0, 0
+	for distance, count := range counts {
+		if count > best_count || count == best_count && distance < period {
+			period, best_count = distance, count
+		}
+	}
+	if period <= 0 {
+		return 0, 0.0
+	}
+
+	multiples := 0
+	for _, distance := range cell_distances {
+		if distance > 0 && distance % period == 0 {
+			multiples += 1
+		}
+	}
+	return period, float64(multiples) / float64(len(cell_distances))
+}
@@ -0,0 +1,152 @@
+package gridlines
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/types"
+)
+
+/*
+	Builds a combined list of cells of 6 pixels separated by 1 pixel gridlines, where borders listed in wide
+	are unknown items 3 pixels wide. Border k follows cell k, borders are counted from 0.
+*/
+func combinedWithWideBorders(cells int, wide map[int]bool) types.CombinedList {
+	var combined types.CombinedList
+	for cell := 0; cell < cells; cell++ {
+		combined.Intervals = append(combined.Intervals, 6)
+		combined.IntervalTypes = append(combined.IntervalTypes, types.INTERVAL_PIXEL)
+		if cell == cells - 1 {
+			break
+		}
+		if wide[cell] {
+			combined.Intervals = append(combined.Intervals, 3)
+			combined.IntervalTypes = append(combined.IntervalTypes, types.INTERVAL_UNKNOWN)
+		} else {
+			combined.Intervals = append(combined.Intervals, 1)
+			combined.IntervalTypes = append(combined.IntervalTypes, types.INTERVAL_GRID)
+		}
+	}
+	return combined
+}
+
+/*
+	Every 5th border of 40 cells is a wide major gridline, one more wide item at border 12 is off the period.
+	Only major gridlines on the period are marked as grid, the off-period item is left unknown.
+*/
+func TestMarkMajorGridlinesSkipsOffPeriod(t *testing.T) {
+	wide := map[int]bool{12: true}
+	for border := 4; border < 39; border += 5 {
+		wide[border] = true
+	}
+	combined := combinedWithWideBorders(40, wide)
+	pixel_guess := types.IntervalRangeEntry{Bounds: [2]int{6, 6}, Count: 40, Mean: 6}
+	grid_guess := types.IntervalRangeEntry{Bounds: [2]int{1, 1}, Count: 31, Mean: 1}
+
+	major := DetectMajorGrid(combined, pixel_guess, grid_guess, GetBaseMajorGridParams())
+	if major.Period != 5 {
+		t.Fatalf("major grid period: expected 5, got %d", major.Period)
+	}
+
+	marked := MarkMajorGridlines(combined, pixel_guess, grid_guess, major)
+	for border := 0; border < 39; border++ {
+		item_type := marked.IntervalTypes[2 * border + 1]
+		switch {
+		case border == 12 && item_type != types.INTERVAL_UNKNOWN:
+			t.Errorf("off-period wide item at border %d: expected unknown, got type %d", border, item_type)
+		case border != 12 && item_type != types.INTERVAL_GRID:
+			t.Errorf("border %d: expected grid, got type %d", border, item_type)
+		}
+	}
+}
+
+/*
+	Builds gridline colours and strengths of an axis of given length with 1 pixel gridlines every period pixels,
+	see contrast.GridlineColors. line returns the colour of gridline at border k and false if the border has none.
+*/
+func gridlineColorsOfBorders(period float64, length int, line func(border int) ([4]uint8, bool)) ([][4]uint8, []float64) {
+	colors := make([][4]uint8, length)
+	strengths := make([]float64, length)
+	for border := 0; ; border++ {
+		position := int(math.Round(float64(border) * period))
+		if position >= length {
+			break
+		}
+		if color, found := line(border); found {
+			colors[position], strengths[position] = color, 1.0
+		}
+	}
+	return colors, strengths
+}
+
+func TestDetectMajorGridByColor(t *testing.T) {
+	grey := [4]uint8{128, 128, 128, 255}
+	dark := [4]uint8{30, 30, 30, 255}
+	random := rand.New(rand.NewSource(1))
+	palette := [3][4]uint8{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	art := make([][4]uint8, 100)
+	for k := range art {
+		art[k] = palette[random.Intn(len(palette))]
+	}
+
+	cases := []struct {
+		name string
+		period float64
+		line func(border int) ([4]uint8, bool)
+		expected int
+	}{
+		{
+			"dark line every 5 cells", 7,
+			func(border int) ([4]uint8, bool) { return common.Ternary(border % 5 == 0, dark, grey), true },
+			5,
+		},
+		{
+			"dark line every 4 cells of fractional lattice", 7.3,
+			func(border int) ([4]uint8, bool) { return common.Ternary(border % 4 == 0, dark, grey), true },
+			4,
+		},
+		{
+			// 4 cells of 10.25 pixels span 41 pixels, so every 4th gridline is rounded the same way
+			"rounding of fractional lattice", 10.25,
+			func(border int) ([4]uint8, bool) { return common.Ternary(border % 4 == 0, dark, grey), true },
+			0,
+		},
+		{
+			// half transparent gridline takes half of the colour of the art below it
+			"semi-transparent grid over varying art", 7,
+			func(border int) ([4]uint8, bool) {
+				var blended [4]uint8
+				for channel := 0; channel < 3; channel++ {
+					blended[channel] = uint8((int(art[border][channel]) + int(grey[channel])) / 2)
+				}
+				blended[3] = 255
+				return blended, true
+			},
+			0,
+		},
+		{
+			// lines of the art instead of a grid, most borders have no gridline
+			"gridlines at a fifth of borders", 7,
+			func(border int) ([4]uint8, bool) { return common.Ternary(border % 10 == 0, dark, grey), border % 5 == 0 },
+			0,
+		},
+		{
+			"dark lines in a third of the axis", 7,
+			func(border int) ([4]uint8, bool) { return common.Ternary(border % 5 == 0 && border < 30, dark, grey), true },
+			0,
+		},
+	}
+
+	for _, test_case := range cases {
+		lattice := types.Lattice{Period: test_case.period, Phase: 0, GridWidth: 1, Coherence: 1}
+		colors, strengths := gridlineColorsOfBorders(test_case.period, int(90 * test_case.period), test_case.line)
+		major := DetectMajorGridByColor(lattice, colors, strengths, GetBaseMajorGridParams())
+		if major.Period != test_case.expected {
+			t.Errorf("%s: expected period %d, got %d", test_case.name, test_case.expected, major.Period)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package gridlines
+
+import (
+	"math"
+)
+
+import (
+	"pixel_restoration/types"
+)
+
+/*
+	SpectralParams describe the frequency-domain period estimator, see EstimatePeriodSpectral.
+
+	MinPeriod: int
+		Smallest autocorrelation lag considered as a period
+	MaxPeriodFraction: float64
+		Largest considered lag as a fraction of signal length, lower values require more repetitions of the period
+	HarmonicRatio: float64
+		The first autocorrelation peak at least this fraction (0.0 - 1.0) of the strongest one is chosen as the period.
+		Multiples of the period produce peaks as well, so the strongest peak is not always the fundamental one.
+		The same fraction of the strongest DFT response decides between the chosen lag and its divisors.
+	MaxDivisor: int
+		Lag divided by 1 .. MaxDivisor is tried by the DFT stage
+	SearchRange: float64
+		Periods within (1 +- SearchRange) * <autocorrelation period> are searched for the strongest DFT response
+*/
+type SpectralParams struct {
+	MinPeriod int
+	MaxPeriodFraction float64
+	HarmonicRatio float64
+	MaxDivisor int
+	SearchRange float64
+}
+
+func GetBaseSpectralParams() SpectralParams {
+	return SpectralParams{
+		MinPeriod: 2,
+		MaxPeriodFraction: 1.0 / 3.0,
+		HarmonicRatio: 0.7,
+		MaxDivisor: 4,
+		SearchRange: 0.15,
+	}
+}
+
+/*
+	PeriodEstimate is the result of EstimatePeriodSpectral.
+
+	Period: float64
+		Dominant period of the signal (pixel size + gridline width), 0 if no period was found
+	Strength: float64
+		Normalized autocorrelation at the chosen lag (0.0 - 1.0), higher means more regular signal
+	Coherence: float64
+		DFT magnitude at 1 / Period divided by total signal (0.0 - 1.0)
+*/
+type PeriodEstimate struct {
+	Period float64
+	Strength float64
+	Coherence float64
+}
+
+/*
+	EstimatePeriodSpectral finds the dominant period of per-position edge counts (see contrast.EdgeCountsWithMode)
+	independently of interval histograms, so it still works when dithering or noise breaks intervals apart.
+
+	1. Autocorrelation of the mean-removed counts gives a coarse integer period, the first peak
+	   that reaches HarmonicRatio of the strongest one is chosen.
+	2. DFT magnitude is evaluated at continuous frequencies around the coarse period and its divisors,
+	   the strongest response around each of them gives a sub-pixel period.
+	   Non-integer periods (for example 10.5) split autocorrelation peak between two lags, so the coarse lag
+	   is often a multiple of the true period. Edges are impulses, so DFT response at a multiple of the period is weak
+	   while the true period and its divisors respond equally, the longest period with strong response is chosen.
+
+	Returns zero estimate if the signal is too short or has no variance.
+*/
+func EstimatePeriodSpectral(edge_counts []uint, params SpectralParams) PeriodEstimate {
+	max_lag := int(float64(len(edge_counts)) * params.MaxPeriodFraction)
+	min_lag := max(params.MinPeriod, 1)
+	if max_lag <= min_lag {
+		return PeriodEstimate{}
+	}
+
+	autocorrelation := autocorrelationNormalized(edge_counts, max_lag + 1)
+	if autocorrelation == nil {
+		return PeriodEstimate{}
+	}
+
+	coarse_lag := fundamentalLag(autocorrelation, min_lag, max_lag, params.HarmonicRatio)
+	if coarse_lag == 0 {
+		return PeriodEstimate{}
+	}
+
+	period, coherence := 0.0, -1.0
+	var candidates [][2]float64
+	var strongest float64 = 0
+	for divisor := 1; divisor <= max(params.MaxDivisor, 1); divisor++ {
+		coarse_period := float64(coarse_lag) / float64(divisor)
+		if coarse_period < float64(min_lag) {
+			break
+		}
+		candidate_period, candidate_coherence := strongestFrequency(edge_counts, coarse_period, params.SearchRange)
+		candidates = append(candidates, [2]float64{candidate_period, candidate_coherence})
+		strongest = max(strongest, candidate_coherence)
+	}
+	// candidates are ordered from the longest period
+	for _, candidate := range candidates {
+		if candidate[1] >= params.HarmonicRatio * strongest {
+			period, coherence = candidate[0], candidate[1]
+			break
+		}
+	}
+
+	return PeriodEstimate{
+		Period: period,
+		Strength: min(max(autocorrelation[coarse_lag], 0.0), 1.0),
+		Coherence: coherence,
+	}
+}
+
+/*
+	Returns autocorrelation of mean-removed signal for lags 0 .. lag_count-1 divided by its value at lag 0.
+	Each lag is divided by the full signal length (biased estimate), so longer lags are slightly suppressed.
+	Returns nil for a constant signal.
+*/
+func autocorrelationNormalized(signal []uint, lag_count int) []float64 {
+	var mean float64 = 0
+	for _, value := range signal {
+		mean += float64(value)
+	}
+	mean /= float64(len(signal))
+
+	centred := make([]float64, len(signal))
+	for i, value := range signal {
+		centred[i] = float64(value) - mean
+	}
+
+	result := make([]float64, lag_count)
+	for lag := 0; lag < lag_count; lag++ {
+		var sum float64 = 0
+		for i := 0; i + lag < len(centred); i++ {
+			sum += centred[i] * centred[i + lag]
+		}
+		result[lag] = sum
+	}
+
+	if result[0] <= 0 {
+		return nil
+	}
+	for lag := lag_count - 1; lag >= 0; lag-- {
+		result[lag] /= result[0]
+	}
+	return result
+}
+
+/*
+	Returns the first local maximum of autocorrelation in [min_lag, max_lag] that reaches
+	harmonic_ratio * <strongest local maximum>, 0 if there are no positive local maxima.
+*/
+func fundamentalLag(autocorrelation []float64, min_lag, max_lag int, harmonic_ratio float64) int {
+	is_peak := func(lag int) bool {
+		left := autocorrelation[lag - 1]
+		right := math.Inf(-1)
+		if lag + 1 < len(autocorrelation) {
+			right = autocorrelation[lag + 1]
+		}
+		return autocorrelation[lag] > 0 && autocorrelation[lag] >= left && autocorrelation[lag] >= right
+	}
+
+	var strongest float64 = 0
+	for lag := min_lag; lag <= max_lag; lag++ {
+		if is_peak(lag) {
+			strongest = max(strongest, autocorrelation[lag])
+		}
+	}
+	if strongest <= 0 {
+		return 0
+	}
+
+	for lag := min_lag; lag <= max_lag; lag++ {
+		if is_peak(lag) && autocorrelation[lag] >= harmonic_ratio * strongest {
+			return lag
+		}
+	}
+	return 0
+}
+
+/*
+	Evaluates DFT magnitude of the signal at periods within (1 +- search_range) * coarse_period
+	and returns the period with the strongest response along with the magnitude divided by signal sum.
+*/
+func strongestFrequency(signal []uint, coarse_period float64, search_range float64) (float64, float64) {
+	var total float64 = 0
+	for _, value := range signal {
+		total += float64(value)
+	}
+	if total == 0 {
+		return coarse_period, 0
+	}
+
+	low := max(coarse_period * (1.0 - search_range), 1.5)
+	high := coarse_period * (1.0 + search_range)
+	// response peak is about period^2 / length wide, so a few steps per peak width are enough
+	step := max(coarse_period * coarse_period / (8.0 * float64(len(signal))), 0.001)
+
+	best_period, best_magnitude := coarse_period, -1.0
+	for period := low; period <= high; period += step {
+		var sum_sin, sum_cos float64
+		for position, value := range signal {
+			if value == 0 {
+				continue
+			}
+			angle := 2.0 * math.Pi * float64(position) / period
+			sum_sin += float64(value) * math.Sin(angle)
+			sum_cos += float64(value) * math.Cos(angle)
+		}
+		magnitude := math.Hypot(sum_sin, sum_cos)
+		if magnitude > best_magnitude {
+			best_period, best_magnitude = period, magnitude
+		}
+	}
+	return best_period, best_magnitude / total
+}
+
+/*
+	Builds pixel and gridline range entries from a period found by a different estimator.
+	Gridline guess is kept if it is non-zero and shorter than half of the period, pixel size takes the rest of the period.
+	Count of the pixel entry is the number of non-edge intervals within its bounds.
+*/
+func GuessFromPeriod(period float64, grid_guess types.IntervalRangeEntry, intervals types.IntervalList) (types.IntervalRangeEntry, types.IntervalRangeEntry) {
+	grid := types.GetZeroRangeEntry()
+	if grid_guess.Bounds[1] > 0 && grid_guess.Mean < period / 2.0 {
+		grid = grid_guess
+	}
+
+	pixel_mean := max(period - grid.Mean, 1.0)
+	pixel := types.IntervalRangeEntry{
+		Bounds: [2]int{max(int(math.Floor(pixel_mean)), 1), max(int(math.Ceil(pixel_mean)), 1)},
+		Mean: pixel_mean,
+	}
+	for i := 1; i < len(intervals.Intervals) - 1; i++ {
+		length := int(intervals.Intervals[i])
+		if pixel.Bounds[0] <= length && length <= pixel.Bounds[1] {
+			pixel.Count += 1
+		}
+	}
+	return pixel, grid
+}
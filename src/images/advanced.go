@@ -3,6 +3,8 @@ package images
 import "image"
 import "fmt"
 
+import "pixel_restoration/common"
+
 /*
 	Given <src> RGBA image, upscale it by expanding each original pixel to <pixel_size> x <pixel_size> square block
 	If <grid_size> is more than 0, inserts <grid_color> colored gridlines between each square block, 
@@ -10,11 +12,14 @@ import "fmt"
 
 	Resulting image is saved to <dst> RGBA image provided by the caller. 
 	Both <src> and <dst> can be subimages (images where rectangle doesn't start at 0,0 and stride doesn't equal 4 * Dx)
-	Function will panic if rectangle of <dst> has wrong dimensions.
+	Returns ErrInvalidUpscaleParams if any image is nil, pixel size is 0 or rectangle of <dst> has wrong dimensions,
+	in that case <dst> is not modified.
 */
 
-func AdvancedUpscale(src, dst *image.RGBA, pixel_size, grid_size uint, grid_color [4]uint8){
-	advancedUpscaleValidateArguments(src, dst, pixel_size, grid_size)
+func AdvancedUpscale(src, dst *image.RGBA, pixel_size, grid_size uint, grid_color [4]uint8) error {
+	if err := advancedUpscaleValidateArguments(src, dst, pixel_size, grid_size); err != nil {
+		return err
+	}
 	RGBAFillColor(dst, grid_color)
 
 	src_base_index := -src.PixOffset(0, 0)
@@ -62,38 +67,39 @@ func AdvancedUpscale(src, dst *image.RGBA, pixel_size, grid_size uint, grid_colo
 			copy(dst_target_row, dst_row_for_copy)
 		}
 	}
+	return nil
 }
 
 /*
 	Does basic validation on input data for function AdvancedUpscale.
-	Panics on invalid arguments, does nothing otherwise.
+	Returns ErrInvalidUpscaleParams wrapped with description of invalid argument, nil otherwise.
 
 	Does not concern itself with check if dst and src overlap. 
 	Does not check if image.RGBA arguments are internally consistent 
 	(Doesnt validate against nonsensical structs being passed)
 
 */
-func advancedUpscaleValidateArguments(src, dst *image.RGBA, pixel_size, grid_size uint){
+func advancedUpscaleValidateArguments(src, dst *image.RGBA, pixel_size, grid_size uint) error {
 	if src == nil {
-		panic("src parameter cannot be nil")
+		return fmt.Errorf("%w: src parameter cannot be nil", common.ErrInvalidUpscaleParams)
 	}
 	if dst == nil{
-		panic("src parameter cannot be nil")
+		return fmt.Errorf("%w: dst parameter cannot be nil", common.ErrInvalidUpscaleParams)
 	}
 	if pixel_size == 0 {
-		panic("pixel_size parameter must be larger than 0")
+		return fmt.Errorf("%w: pixel_size parameter must be larger than 0", common.ErrInvalidUpscaleParams)
 	}
 	expected_dim := AdvancedUpscaleGetResultDimensions(src.Rect, pixel_size, grid_size)
 	var dimensions_ok bool = dst.Rect.Dx() == expected_dim.Dx() && dst.Rect.Dy() == expected_dim.Dy()
 	if !dimensions_ok {
-		message := fmt.Sprintf(
-			"wrong dimensions of destination image\n" +
+		return fmt.Errorf(
+			"%w: wrong dimensions of destination image, " +
 			"expected [width, height]: [%d, %d], got: [%d, %d]",
+			common.ErrInvalidUpscaleParams,
 			expected_dim.Dx(), expected_dim.Dy(), dst.Rect.Dx(), dst.Rect.Dy(),
 		)
-		panic(message)
 	}
-
+	return nil
 }
 
 /*
@@ -119,11 +125,17 @@ func AdvancedUpscaleGetResultDimensions(src_rect image.Rectangle, pixel_size, gr
 
 	Resulting image is not a subimage.
 	(result.Rect.Min == 0,0 , result.Stride == result.Rect.Dx() * 4)
+	Returns ErrInvalidUpscaleParams if <src> is nil or pixel size is 0.
 
 */
-func AdvancedUpscaleGetNewImage(src *image.RGBA, pixel_size, grid_size uint, grid_color [4]uint8) *image.RGBA {
+func AdvancedUpscaleGetNewImage(src *image.RGBA, pixel_size, grid_size uint, grid_color [4]uint8) (*image.RGBA, error) {
+	if src == nil {
+		return nil, fmt.Errorf("%w: src parameter cannot be nil", common.ErrInvalidUpscaleParams)
+	}
 	var dst_rect image.Rectangle = AdvancedUpscaleGetResultDimensions(src.Rect, pixel_size, grid_size)
 	var dst *image.RGBA = image.NewRGBA(dst_rect)
-	AdvancedUpscale(src, dst, pixel_size, grid_size, grid_color)
-	return dst
+	if err := AdvancedUpscale(src, dst, pixel_size, grid_size, grid_color); err != nil {
+		return nil, err
+	}
+	return dst, nil
 }
\ No newline at end of file
@@ -2,8 +2,8 @@
 	Alterations file contains functions that alter the image files in the following ways:
 	- upscale (also upscale with gridlines)
 	- draw gridlines
-	- add noise to image <not yet added>
-	- add watermakrs to image <not yet added>
+	- add noise to image
+	- add watermakrs to image
 */
 
 package images
@@ -13,6 +13,12 @@ import (
 	"image/draw"
 	"image/color"
 	"fmt"
+	"math"
+	"math/rand"
+)
+
+import (
+	"pixel_restoration/common"
 )
 
 
@@ -21,18 +27,18 @@ import (
 
 	DrawGridlineRowsOnImage fills selected rows of input image with provided RGBA color.
 	Selected rows are chosen based on y_indexes slice. 
-	If y_indexes holds invalid row index, function will panic.
+	If y_indexes holds invalid row index, returns ErrIndexOutOfRange and the image is not modified.
 
 */
-func DrawGridlineRowsOnImage(img *image.RGBA, y_indexes []int, color [4]uint8) {
+func DrawGridlineRowsOnImage(img *image.RGBA, y_indexes []int, color [4]uint8) error {
 	height, width := img.Rect.Dy(), img.Rect.Dx()
 	//validate y_indexes
 	for _, y_id := range y_indexes{
 		if y_id < 0 {
-			panic(fmt.Sprintf("Provided y_index %d is less than 0", y_id))
+			return fmt.Errorf("%w: provided y_index %d is less than 0", common.ErrIndexOutOfRange, y_id)
 		}
 		if y_id > height - 1 {
-			panic(fmt.Sprintf("Provided y_index %d is larger than image height - 1 (%d)", y_id, height - 1))
+			return fmt.Errorf("%w: provided y_index %d is larger than image height - 1 (%d)", common.ErrIndexOutOfRange, y_id, height - 1)
 		}
 	}
 
@@ -44,24 +50,25 @@ func DrawGridlineRowsOnImage(img *image.RGBA, y_indexes []int, color [4]uint8) {
 			copy(destination, color[:])
 		}
 	}
+	return nil
 }
 
 /*
 
 	DrawGridlineColsOnImage fills selected columns of input image with provided RGBA color.
 	Selected columns are chosen based on x_indexes slice. 
-	If x_indexes holds invalid row index, function will panic.
+	If x_indexes holds invalid column index, returns ErrIndexOutOfRange and the image is not modified.
 
 */
-func DrawGridlineColsOnImage(img *image.RGBA, x_indexes []int, color [4]uint8){
+func DrawGridlineColsOnImage(img *image.RGBA, x_indexes []int, color [4]uint8) error {
 	height, width := img.Rect.Dy(), img.Rect.Dx()
-	//validate y_indexes
+	//validate x_indexes
 	for _, x_id := range x_indexes{
 		if x_id < 0 {
-			panic(fmt.Sprintf("Provided y_index %d is less than 0", x_id))
+			return fmt.Errorf("%w: provided x_index %d is less than 0", common.ErrIndexOutOfRange, x_id)
 		}
 		if x_id > width - 1 {
-			panic(fmt.Sprintf("Provided y_index %d is larger than image width - 1 (%d)", x_id, width - 1))
+			return fmt.Errorf("%w: provided x_index %d is larger than image width - 1 (%d)", common.ErrIndexOutOfRange, x_id, width - 1)
 		}
 	}
 
@@ -73,6 +80,7 @@ func DrawGridlineColsOnImage(img *image.RGBA, x_indexes []int, color [4]uint8){
 			copy(destination, color[:])
 		}
 	}
+	return nil
 }
 
 
@@ -91,3 +99,80 @@ func RGBAFillColor(img *image.RGBA, fill_color [4]uint8){
 	}
 	draw.Draw(img, img.Bounds(), &image.Uniform{color_rgba}, img.Bounds().Min, draw.Src)
 }
+
+
+/*
+	ImageAddGaussianNoise adds gaussian noise with standard deviation <sigma> to R, G and B channels of the image in place.
+	Each channel of each pixel receives independent noise value, results are clamped to 0-255.
+	Alpha channel is left untouched.
+*/
+func ImageAddGaussianNoise(img *image.RGBA, sigma float64, rng *rand.Rand) {
+	height, width := img.Rect.Dy(), img.Rect.Dx()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+			for channel := 0; channel < 3; channel++ {
+				noisy := float64(img.Pix[flat_id + channel]) + rng.NormFloat64() * sigma
+				img.Pix[flat_id + channel] = clampToUint8(noisy)
+			}
+		}
+	}
+}
+
+/*
+	ImageAddSaltPepperNoise sets randomly chosen pixels to either black or white in place.
+	<amount> is the probability of each pixel being changed (0.0 - 1.0), black and white are equally likely.
+	Alpha channel of changed pixels is set to 255.
+*/
+func ImageAddSaltPepperNoise(img *image.RGBA, amount float64, rng *rand.Rand) {
+	height, width := img.Rect.Dy(), img.Rect.Dx()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if rng.Float64() >= amount {
+				continue
+			}
+			var value uint8 = 0
+			if rng.Intn(2) == 1 {
+				value = 255
+			}
+			flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+			copy(img.Pix[flat_id: flat_id + 4], []uint8{value, value, value, 255})
+		}
+	}
+}
+
+/*
+	ImageBlendOver draws <overlay> over <img> in place, with top left corner of overlay placed at <position>
+	(relative to img.Rect.Min). Overlay alpha channel multiplied by <opacity> (0.0 - 1.0) is used as blending weight.
+	Parts of the overlay outside of img are ignored. Used for drawing watermarks.
+*/
+func ImageBlendOver(img, overlay *image.RGBA, position image.Point, opacity float64) {
+	height, width := img.Rect.Dy(), img.Rect.Dx()
+	overlay_height, overlay_width := overlay.Rect.Dy(), overlay.Rect.Dx()
+
+	for oy := 0; oy < overlay_height; oy++ {
+		y := position.Y + oy
+		if y < 0 || y >= height {
+			continue
+		}
+		for ox := 0; ox < overlay_width; ox++ {
+			x := position.X + ox
+			if x < 0 || x >= width {
+				continue
+			}
+			overlay_id := overlay.PixOffset(ox + overlay.Rect.Min.X, oy + overlay.Rect.Min.Y)
+			flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+
+			weight := float64(overlay.Pix[overlay_id + 3]) / 255.0 * opacity
+			for channel := 0; channel < 3; channel++ {
+				blended := float64(img.Pix[flat_id + channel]) * (1.0 - weight) + float64(overlay.Pix[overlay_id + channel]) * weight
+				img.Pix[flat_id + channel] = clampToUint8(blended)
+			}
+		}
+	}
+}
+
+// rounds float value to nearest uint8, values outside of 0-255 range are clamped
+func clampToUint8(value float64) uint8 {
+	return uint8(math.Max(0.0, math.Min(255.0, math.Round(value))))
+}
@@ -1,6 +1,7 @@
 package images
 
 import (
+	"errors"
 	"image"
     "image/png"
     _ "image/jpeg"
@@ -22,7 +23,7 @@ func RGBALoadFromFile(filepath string) (*image.RGBA, error){
 		return nil, err
 	}
 
-	return getRGBAFromImage(imageData) , nil
+	return RGBAFromImage(imageData) , nil
 }
 
 func RGBASaveToFile(filepath string, img *image.RGBA) error {
@@ -30,14 +31,7 @@ func RGBASaveToFile(filepath string, img *image.RGBA) error {
  	if err != nil {
    		return err
     }
-    defer outfile.Close()
-
-	err = png.Encode(outfile, img)
-	if err != nil {
-   		return err
-	}
-
-	return nil
+	return errors.Join(png.Encode(outfile, img), outfile.Close())
 }
 
 
@@ -46,21 +40,17 @@ func GraySaveToFile(filepath string, img *image.Gray) error {
  	if err != nil {
    		return err
     }
-    defer outfile.Close()
-
-	err = png.Encode(outfile, img)
-	if err != nil {
-   		return err
-	}
-
-	return nil
+	return errors.Join(png.Encode(outfile, img), outfile.Close())
 
 }
 
 
-func getRGBAFromImage(src image.Image) (*image.RGBA){
+/*
+	Converts any image to a new normalized RGBA image
+*/
+func RGBAFromImage(src image.Image) (*image.RGBA){
 	bounds := src.Bounds()
 	converted := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
-	draw.Draw(converted, bounds, src, bounds.Min, draw.Src)
+	draw.Draw(converted, converted.Bounds(), src, bounds.Min, draw.Src)
 	return converted
 }
\ No newline at end of file
@@ -28,14 +28,15 @@ func getGreyscaledChannel(img *image.RGBA) []float32 {
 }
 
 /* 
-	getSplitChannels seturns array of 3 slices of uint8, each slice has different memory block
-	each uint8 slice corresponds to flattened R, G, B channel of the image, alpha is discarded
+	getSplitChannels seturns slice of 4 slices of uint8, each slice has different memory block
+	each uint8 slice corresponds to flattened R, G, B, A channel of the image
 */
 
-func getSplitChannels(img *image.RGBA) [3][]uint8 {
+func getSplitChannels(img *image.RGBA) [][]uint8 {
 	var channel_size int = img.Rect.Dy() * img.Rect.Dx()
 
-	channels := [3][]uint8{ 
+	channels := [][]uint8{ 
+		make([]uint8, channel_size),
 		make([]uint8, channel_size),
 		make([]uint8, channel_size),
 		make([]uint8, channel_size),
@@ -49,6 +50,7 @@ func getSplitChannels(img *image.RGBA) [3][]uint8 {
 			channels[0][id_channel] = img.Pix[flat_id + 0]
 			channels[1][id_channel] = img.Pix[flat_id + 1]
 			channels[2][id_channel] = img.Pix[flat_id + 2]
+			channels[3][id_channel] = img.Pix[flat_id + 3]
 		}
 
 	}
@@ -1,16 +1,28 @@
 package kuwahara
 
 import (
+	"fmt"
 	"image"
 )
 
+import (
+	"pixel_restoration/common"
+)
+
 
-func KuwaharaGaussian(img *image.RGBA, radius int, sigma float32) *image.RGBA{
+/*
+	Applies Kuwahara filter with gaussian weighted quadrants to the image and returns the filtered copy.
+	Alpha channel of images with transparent pixels is averaged like colour channels and its variance
+	is added to the variance of brightness, so quadrants don't mix transparent background into opaque art.
+	Images without transparent pixels keep alpha at 255.
+	Returns ErrInvalidFilterParams if radius is lower than 1 and ErrImageTooSmall if image has no pixels.
+*/
+func KuwaharaGaussian(img *image.RGBA, radius int, sigma float32) (*image.RGBA, error){
 	if radius < 1 {
-		panic("Radius must be bigger than 0")
+		return nil, fmt.Errorf("%w: kuwahara radius %d must be at least 1", common.ErrInvalidFilterParams, radius)
 	}
-	if img.Rect.Dx() * img.Rect.Dy() == 0 {
-		panic("Image must have at least one pixel")
+	if img == nil || img.Rect.Dx() * img.Rect.Dy() == 0 {
+		return nil, fmt.Errorf("%w: kuwahara filter needs at least one pixel", common.ErrImageTooSmall)
 	}
 
 	img_shape := [2]int{
@@ -51,9 +63,24 @@ func KuwaharaGaussian(img *image.RGBA, radius int, sigma float32) *image.RGBA{
 	)
 
 
-	// calculating color averages
+	// transparent and opaque areas of the same brightness differ only in alpha
 	channels := getSplitChannels(img)
-	var color_averages [4][3][]uint8 = getColorAverages(
+	if !img.Opaque() {
+		alpha := make([]float32, total_count)
+		sliceUint8ToFloat32(channels[3], alpha)
+		alpha_deviations := calculateStandardDeviations(
+			alpha, img_shape, total_count,
+			kernel_quadrants, kernel_anchors,
+		)
+		for kernel_id := 0; kernel_id < 4; kernel_id++ {
+			sliceAddFloat32(standard_deviations[kernel_id], alpha_deviations[kernel_id])
+		}
+	} else {
+		channels = channels[:3]
+	}
+
+	// calculating color averages
+	var color_averages [4][][]uint8 = getColorAverages(
 		channels, img_shape, total_count,
 		kernel_quadrants, kernel_anchors,
 	)
@@ -74,7 +101,7 @@ func KuwaharaGaussian(img *image.RGBA, radius int, sigma float32) *image.RGBA{
 		Pix : new_data,
 		Stride: new_stride,
 		Rect: new_rect,
-	}
+	}, nil
 }	
 
 
@@ -132,15 +159,20 @@ func calculateStandardDeviations(greyscale []float32, img_shape [2] int , total_
 }
 
 
-func getColorAverages(channels [3][]uint8, img_shape [2]int, total_count int,
-				kernel_quadrants [4][2][]float32, kernel_anchors [4][2]int) [4][3][]uint8{
+/*
+	Returns gaussian weighted averages of every channel in all 4 quadrants, indexed as [quadrant][channel][pixel]
+*/
+func getColorAverages(channels [][]uint8, img_shape [2]int, total_count int,
+				kernel_quadrants [4][2][]float32, kernel_anchors [4][2]int) [4][][]uint8{
 
 	// making space for result array
-	var color_averages [4][3][]uint8
-	color_averages_buffer := make([]uint8, 3 * 4 * total_count)
+	channel_count := len(channels)
+	var color_averages [4][][]uint8
+	color_averages_buffer := make([]uint8, channel_count * 4 * total_count)
 	for kernel_id := 0; kernel_id < 4 ; kernel_id++{
-		for channel_id := 0; channel_id < 3; channel_id++{
-			start := (kernel_id * 3 + channel_id) * total_count
+		color_averages[kernel_id] = make([][]uint8, channel_count)
+		for channel_id := 0; channel_id < channel_count; channel_id++{
+			start := (kernel_id * channel_count + channel_id) * total_count
 			end := start + total_count
 			color_averages[kernel_id][channel_id] = color_averages_buffer[start: end]
 		}
@@ -152,7 +184,7 @@ func getColorAverages(channels [3][]uint8, img_shape [2]int, total_count int,
 	temporary := make([]float32, total_count)
 
 	// calculating color averages
-	for channel_id := 0; channel_id < 3; channel_id++{
+	for channel_id := 0; channel_id < channel_count; channel_id++{
 		sliceUint8ToFloat32(channels[channel_id], channel_float)
 		for kernel_id := 0; kernel_id < 4; kernel_id++ {
 		  	sepFilter2D(
@@ -187,7 +219,10 @@ func chooseQuadrants(standard_deviations [4][]float32) []uint8{
 }
 
 
-func takeAveragesFromChosenQuadrants(color_averages [4][3][]uint8, quadrants_chosen []uint8 ) []uint8 {
+/*
+	Assembles RGBA pixel data from averages of chosen quadrants, alpha is 255 if averages hold only colour channels
+*/
+func takeAveragesFromChosenQuadrants(color_averages [4][][]uint8, quadrants_chosen []uint8 ) []uint8 {
 	count := len(quadrants_chosen)
 	result := make([]uint8, count * 4)
 
@@ -198,7 +233,10 @@ func takeAveragesFromChosenQuadrants(color_averages [4][3][]uint8, quadrants_cho
 		result[flat_id_result + 0] = color_averages[chosen_quadrant][0][flat_id]
 		result[flat_id_result + 1] = color_averages[chosen_quadrant][1][flat_id]
 		result[flat_id_result + 2] = color_averages[chosen_quadrant][2][flat_id]
-		result[flat_id_result + 3] = 255        // alpha channel constant
+		result[flat_id_result + 3] = 255        // alpha channel constant for opaque images
+		if len(color_averages[chosen_quadrant]) > 3 {
+			result[flat_id_result + 3] = color_averages[chosen_quadrant][3][flat_id]
+		}
 		
 	}
 	return result
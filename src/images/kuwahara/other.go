@@ -30,6 +30,12 @@ func sliceSubtractSquared(slice []float32, other []float32){
 	}
 }
 
+func sliceAddFloat32(slice []float32, other []float32){
+	for id := range slice {
+		slice[id] += other[id]
+	}
+}
+
 
 func sliceFloat32ToUint8(floats []float32, uints []uint8){
 	for id := range floats {
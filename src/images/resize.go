@@ -0,0 +1,129 @@
+/*
+	Resize file contains functions that rescale images by arbitrary (also non-integer) factors
+	- bilinear interpolation
+	- bicubic interpolation
+*/
+
+package images
+
+import (
+	"image"
+	"math"
+)
+
+/*
+	Defines a set of uint8 constants used to select interpolation used by ImageGetResized
+*/
+const (
+	INTERPOLATION_BILINEAR uint8 = iota
+	INTERPOLATION_BICUBIC uint8 = iota
+)
+
+/*
+	ImageGetResized makes an entirely new RGBA image of size <new_width> x <new_height>
+	with content of input image rescaled using selected interpolation.
+
+	Pixel centers are aligned, meaning that source coordinate of destination pixel x is (x + 0.5) * scale - 0.5.
+	Samples outside of the source image are clamped to the nearest edge pixel.
+	Resulting image is always normalized, see ImageGetNormalized for more info.
+*/
+func ImageGetResized(img *image.RGBA, new_width, new_height int, interpolation uint8) *image.RGBA {
+	height, width := img.Rect.Dy(), img.Rect.Dx()
+	result := image.NewRGBA(image.Rect(0, 0, new_width, new_height))
+	if width == 0 || height == 0 {
+		return result
+	}
+
+	scale_x := float64(width) / float64(new_width)
+	scale_y := float64(height) / float64(new_height)
+
+	for y := 0; y < new_height; y++ {
+		src_y := (float64(y) + 0.5) * scale_y - 0.5
+		for x := 0; x < new_width; x++ {
+			src_x := (float64(x) + 0.5) * scale_x - 0.5
+
+			var color [4]float64
+			if interpolation == INTERPOLATION_BICUBIC {
+				color = sampleBicubic(img, src_x, src_y)
+			} else {
+				color = sampleBilinear(img, src_x, src_y)
+			}
+
+			flat_id := result.PixOffset(x, y)
+			for channel := 0; channel < 4; channel++ {
+				result.Pix[flat_id + channel] = clampToUint8(color[channel])
+			}
+		}
+	}
+	return result
+}
+
+/*
+	Returns channel values of pixel (x, y) of the image as floats, coordinates are clamped to image bounds
+*/
+func pixelClamped(img *image.RGBA, x, y int) [4]float64 {
+	x = max(0, min(x, img.Rect.Dx() - 1))
+	y = max(0, min(y, img.Rect.Dy() - 1))
+	flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+
+	var result [4]float64
+	for channel := 0; channel < 4; channel++ {
+		result[channel] = float64(img.Pix[flat_id + channel])
+	}
+	return result
+}
+
+func sampleBilinear(img *image.RGBA, src_x, src_y float64) [4]float64 {
+	x0, y0 := math.Floor(src_x), math.Floor(src_y)
+	fx, fy := src_x - x0, src_y - y0
+
+	top_left := pixelClamped(img, int(x0), int(y0))
+	top_right := pixelClamped(img, int(x0) + 1, int(y0))
+	bottom_left := pixelClamped(img, int(x0), int(y0) + 1)
+	bottom_right := pixelClamped(img, int(x0) + 1, int(y0) + 1)
+
+	var result [4]float64
+	for channel := 0; channel < 4; channel++ {
+		top := top_left[channel] * (1.0 - fx) + top_right[channel] * fx
+		bottom := bottom_left[channel] * (1.0 - fx) + bottom_right[channel] * fx
+		result[channel] = top * (1.0 - fy) + bottom * fy
+	}
+	return result
+}
+
+func sampleBicubic(img *image.RGBA, src_x, src_y float64) [4]float64 {
+	x0, y0 := math.Floor(src_x), math.Floor(src_y)
+	fx, fy := src_x - x0, src_y - y0
+
+	var weights_x, weights_y [4]float64
+	for i := 0; i < 4; i++ {
+		weights_x[i] = cubicWeight(fx - float64(i - 1))
+		weights_y[i] = cubicWeight(fy - float64(i - 1))
+	}
+
+	var result [4]float64
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			pixel := pixelClamped(img, int(x0) + i - 1, int(y0) + j - 1)
+			weight := weights_x[i] * weights_y[j]
+			for channel := 0; channel < 4; channel++ {
+				result[channel] += pixel[channel] * weight
+			}
+		}
+	}
+	return result
+}
+
+/*
+	Keys cubic convolution kernel with a = -0.5
+*/
+func cubicWeight(distance float64) float64 {
+	const a = -0.5
+	distance = math.Abs(distance)
+	if distance <= 1.0 {
+		return (a + 2.0) * distance * distance * distance - (a + 3.0) * distance * distance + 1.0
+	} else if distance < 2.0 {
+		return a * distance * distance * distance - 5.0 * a * distance * distance + 8.0 * a * distance - 4.0 * a
+	}
+	return 0.0
+}
@@ -0,0 +1,50 @@
+package images
+
+import (
+	"image"
+	"math"
+)
+
+/*
+	ImageGetRotated makes an entirely new RGBA image of the same size as input image
+	with content rotated by <angle> degrees around the image centre, positive angle rotates counter-clockwise
+	as the image is displayed (with Y axis pointing down).
+
+	Samples outside of the source image are clamped to the nearest edge pixel, so corners uncovered by rotation
+	repeat border colours instead of introducing new edges.
+	Resulting image is always normalized, see ImageGetNormalized for more info.
+*/
+func ImageGetRotated(img *image.RGBA, angle float64, interpolation uint8) *image.RGBA {
+	height, width := img.Rect.Dy(), img.Rect.Dx()
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == 0 || height == 0 {
+		return result
+	}
+
+	radians := angle * math.Pi / 180.0
+	sin, cos := math.Sin(radians), math.Cos(radians)
+	centre_x, centre_y := float64(width - 1) / 2.0, float64(height - 1) / 2.0
+
+	for y := 0; y < height; y++ {
+		dy := float64(y) - centre_y
+		for x := 0; x < width; x++ {
+			dx := float64(x) - centre_x
+			// inverse rotation, finds the source of destination pixel
+			src_x := centre_x + dx * cos - dy * sin
+			src_y := centre_y + dx * sin + dy * cos
+
+			var color [4]float64
+			if interpolation == INTERPOLATION_BICUBIC {
+				color = sampleBicubic(img, src_x, src_y)
+			} else {
+				color = sampleBilinear(img, src_x, src_y)
+			}
+
+			flat_id := result.PixOffset(x, y)
+			for channel := 0; channel < 4; channel++ {
+				result.Pix[flat_id + channel] = clampToUint8(color[channel])
+			}
+		}
+	}
+	return result
+}
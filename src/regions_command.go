@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+import (
+	"pixel_restoration/images"
+	"pixel_restoration/restore"
+)
+
+func commandRegions(args []string) int {
+	flags := flag.NewFlagSet("regions", flag.ContinueOnError)
+	options := restore.GetBaseOptions()
+	apply_pipeline_flags := addPipelineFlags(flags, &options)
+	output_dir := flags.String("dir", "regions", "directory for restored region images, created if missing")
+	flags.IntVar(&options.Regions.BlockSize, "block", options.Regions.BlockSize,
+		"side of blocks with separate period estimates, pixel sizes above a third of it are not detected")
+	flags.Float64Var(&options.Regions.PeriodTolerance, "period-tolerance", options.Regions.PeriodTolerance,
+		"maximum relative difference of periods of neighbouring blocks of the same region")
+	flags.IntVar(&options.Regions.MinBlocks, "min-blocks", options.Regions.MinBlocks, "regions with fewer blocks are dropped")
+
+	input_path, code := parseCommandFlags(flags, args)
+	if code != EXIT_OK || input_path == "" {
+		return code
+	}
+	if err := apply_pipeline_flags(); err != nil {
+		fmt.Fprintf(os.Stderr, "regions: %v\n", err)
+		return EXIT_USAGE
+	}
+
+	img, err := images.RGBALoadFromFile(input_path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "regions: cannot load input image: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	regions, err := restore.RestoreRegions(img, options)
+	if errors.Is(err, restore.ErrInvalidFilterParams) {
+		fmt.Fprintf(os.Stderr, "regions: %v\n", err)
+		return EXIT_USAGE
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "regions: detection failed: %v\n", err)
+		return EXIT_DETECTION_FAILURE
+	}
+	if err := os.MkdirAll(*output_dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "regions: cannot create output directory: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	restored := 0
+	for i, region := range regions {
+		fmt.Printf("Region %d: %v, period %.3f %.3f\n", i, region.Rect, region.Period[0], region.Period[1])
+		if region.Err != nil {
+			fmt.Printf("    detection failed: %v\n", region.Err)
+			continue
+		}
+
+		result := region.Result
+		fmt.Printf("    Pixel size (x, y): %.3f %.3f, grid size: %.3f %.3f\n",
+			result.Rows.PixelGuess.Mean, result.Cols.PixelGuess.Mean, result.Rows.GridGuess.Mean, result.Cols.GridGuess.Mean,
+		)
+		fmt.Printf("    Restored size (width, height): %d %d, confidence %.3f\n",
+			result.Image.Rect.Dx(), result.Image.Rect.Dy(), result.Confidence.Score,
+		)
+		path := filepath.Join(*output_dir, fmt.Sprintf("region_%03d.png", i))
+		if err := images.RGBASaveToFile(path, result.Image); err != nil {
+			fmt.Fprintf(os.Stderr, "regions: cannot save region image: %v\n", err)
+			return EXIT_IO_ERROR
+		}
+		restored += 1
+	}
+
+	if restored == 0 {
+		fmt.Fprintf(os.Stderr, "regions: no region could be restored\n")
+		return EXIT_DETECTION_FAILURE
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,122 @@
+package restore
+
+import (
+	"image"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/contrast"
+)
+
+/*
+	AxisConfidence holds diagnostics of detection on a single axis, all fractions are in range 0.0 - 1.0.
+
+	Score: float64
+		Combined confidence of the axis, product of the factors below
+	RunlengthCoverage: float64
+		Winning runlength score of gridlines.GuessGridlineParameters divided by number of scored intervals.
+		Low coverage means the guessed arrangement describes only a small part of the detected edges.
+	ScoreMargin: float64
+		(winner - runner up) / winner of the runlength scores, 1.0 if the guess was made without comparing scores.
+		Low margin means a different arrangement was almost as likely.
+	UnknownFraction: float64
+		Fraction of axis length covered by INTERVAL_UNKNOWN items of the combined list before fixing,
+		first and last (border) items are not counted.
+	CleanupChanged: int
+		Number of edge pixels removed by contrast.CleanupEdgeArtifacts (short runs and clusters)
+	CleanupFraction: float64
+		CleanupChanged divided by number of edge pixels before cleanup, high values suggest noise or watermarks.
+	Cleanup:
+		Pixels changed by each cleanup operation, see contrast.CleanupCounts
+	PixelSizeFactor: float64
+		1.0 unless pixel size of at most 2 was guessed, then <pixel size> / 3. Such guesses fit any dense set of edges
+		(noise, rotated grids, photos) with full coverage, so they are trusted less than the runlength scores suggest.
+*/
+type AxisConfidence struct {
+	Score float64
+	RunlengthCoverage float64
+	ScoreMargin float64
+	UnknownFraction float64
+	CleanupChanged int
+	CleanupFraction float64
+	Cleanup contrast.CleanupCounts
+	PixelSizeFactor float64
+}
+
+/*
+	Confidence holds diagnostics of a whole detection result.
+
+	Score: float64
+		Overall confidence (0.0 - 1.0), lower of the axis scores multiplied by axis agreement
+	Rows, Cols:
+		Per-axis diagnostics, describing X and Y axis respectively (see Result)
+	AxisAgreement: float64
+		Smaller divided by larger pixel+grid period of both axes, 1.0 means both axes guessed the same grid
+*/
+type Confidence struct {
+	Score float64
+	Rows AxisConfidence
+	Cols AxisConfidence
+	AxisAgreement float64
+}
+
+/*
+	Builds confidence report of a finished pipeline run from values already computed by the pipeline
+*/
+func confidenceFromState(state pipelineState) Confidence {
+	var confidence Confidence
+	confidence.Rows = axisConfidence(state.rows, state.edge_rows_binary, state.cleanup_changed_rows)
+	confidence.Cols = axisConfidence(state.cols, state.edge_cols_binary, state.cleanup_changed_cols)
+
+	rows_period := state.rows.PixelGuess.Mean + state.rows.GridGuess.Mean
+	cols_period := state.cols.PixelGuess.Mean + state.cols.GridGuess.Mean
+	if max(rows_period, cols_period) > 0 {
+		confidence.AxisAgreement = min(rows_period, cols_period) / max(rows_period, cols_period)
+	}
+
+	confidence.Score = min(confidence.Rows.Score, confidence.Cols.Score) * confidence.AxisAgreement
+	return confidence
+}
+
+func axisConfidence(axis AxisResult, edges_binary *image.Gray, cleanup contrast.CleanupCounts) AxisConfidence {
+	cleanup_changed := contrast.CleanupRemoved(cleanup)
+	confidence := AxisConfidence{CleanupChanged: cleanup_changed, Cleanup: cleanup}
+
+	scores := axis.Scores
+	if scores.IntervalCount > 0 {
+		confidence.RunlengthCoverage = min(scores.Winner / float64(scores.IntervalCount), 1.0)
+	}
+	confidence.ScoreMargin = 1.0
+	if scores.Compared {
+		confidence.ScoreMargin = 0.0
+		if scores.Winner > 0 {
+			confidence.ScoreMargin = max(scores.Winner - scores.RunnerUp, 0.0) / scores.Winner
+		}
+	}
+
+	total_length := 0
+	for _, interval := range axis.Combined.Intervals {
+		total_length += int(interval)
+	}
+	if total_length > 0 {
+		confidence.UnknownFraction = float64(unknownLength(axis.Combined)) / float64(total_length)
+	}
+
+	edge_pixels := common.CountNonZeroU8(edges_binary.Pix)
+	if edge_pixels > 0 {
+		confidence.CleanupFraction = float64(cleanup_changed) / float64(edge_pixels)
+	}
+
+	confidence.PixelSizeFactor = 1.0
+	if axis.PixelGuess.Bounds[1] <= 2 {
+		confidence.PixelSizeFactor = axis.PixelGuess.Mean / 3.0
+	}
+
+	// margin and cleanup only lower the score partially, a close call or some noise don't make the guess wrong
+	confidence.Score = confidence.PixelSizeFactor * confidence.RunlengthCoverage *
+		(1.0 - confidence.UnknownFraction) *
+		(0.5 + 0.5 * confidence.ScoreMargin) *
+		(1.0 - 0.5 * confidence.CleanupFraction)
+	return confidence
+}
@@ -0,0 +1,273 @@
+package restore
+
+import (
+	"image"
+	"math"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/types"
+)
+
+/*
+	CropParams describe detection of margins, frames and other borders around the art, see Restore.
+
+	Enabled: bool
+		If false, the whole image is treated as art.
+	Tolerance: uint8
+		Border rows and columns whose channels differ by at most this value along the whole line are uniform and cropped
+	EdgePeriods: float64
+		Sections at the start or end of an axis that have no regular pixel cells and are at least this many
+		pixel+grid periods long are non-periodic borders (UI chrome, text, noise) and are cropped after the first run
+	MinRun: int
+		Number of consecutive pixel and grid items that marks the start of the art on an axis
+	MinCells: float64
+		Non-periodic borders of an axis are kept if less than this many pixel+grid periods would be left,
+		since art with large flat areas has few regular cells near its border
+	MinSize: int
+		Borders are never cropped below this many pixels on either axis
+	AlignedRatio: float64
+		Non-periodic borders are cropped only if the fraction of their colour edges aligned to the cell period is lower
+		than AlignedRatio times the same fraction of the art between them (text, noise, UI chrome).
+		Flat or regular parts of the art that were just missed by edge detection stay.
+
+	Constraints:
+		EdgePeriods > 0
+		MinRun >= 1
+		MinCells >= 1
+		MinSize >= 1
+		0 <= AlignedRatio <= 1.0
+*/
+type CropParams struct {
+	Enabled bool
+	Tolerance uint8
+	EdgePeriods float64
+	MinRun int
+	MinCells float64
+	MinSize int
+	AlignedRatio float64
+}
+
+func GetBaseCropParams() CropParams {
+	return CropParams{
+		Enabled: false,
+		Tolerance: 8,
+		EdgePeriods: 3.0,
+		MinRun: 3,
+		MinCells: 4.0,
+		MinSize: 8,
+		AlignedRatio: 0.5,
+	}
+}
+
+/*
+	Returns rectangle (in image coordinates) left after repeatedly removing uniform rows and columns
+	from all four sides of the image. Each line is compared with its own first pixel,
+	so frames made of several differently coloured lines are removed as well.
+*/
+func cropUniformBorders(img *image.RGBA, params CropParams) image.Rectangle {
+	rect := img.Rect
+	line_is_uniform := func(line image.Rectangle) bool {
+		first := img.PixOffset(line.Min.X, line.Min.Y)
+		for y := line.Min.Y; y < line.Max.Y; y++ {
+			for x := line.Min.X; x < line.Max.X; x++ {
+				flat_id := img.PixOffset(x, y)
+				for channel := 0; channel < 4; channel++ {
+					difference := int(img.Pix[flat_id + channel]) - int(img.Pix[first + channel])
+					if difference > int(params.Tolerance) || -difference > int(params.Tolerance) {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+
+	for changed := true; changed; {
+		changed = false
+		if rect.Dy() > params.MinSize && line_is_uniform(image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y + 1)) {
+			rect.Min.Y, changed = rect.Min.Y + 1, true
+		}
+		if rect.Dy() > params.MinSize && line_is_uniform(image.Rect(rect.Min.X, rect.Max.Y - 1, rect.Max.X, rect.Max.Y)) {
+			rect.Max.Y, changed = rect.Max.Y - 1, true
+		}
+		if rect.Dx() > params.MinSize && line_is_uniform(image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X + 1, rect.Max.Y)) {
+			rect.Min.X, changed = rect.Min.X + 1, true
+		}
+		if rect.Dx() > params.MinSize && line_is_uniform(image.Rect(rect.Max.X - 1, rect.Min.Y, rect.Max.X, rect.Max.Y)) {
+			rect.Max.X, changed = rect.Max.X - 1, true
+		}
+	}
+	return rect
+}
+
+/*
+	Returns lengths of sections at the start and at the end of the combined list of an axis that precede
+	the first (or follow the last) run of params.MinRun consecutive pixel and grid items.
+	Lengths shorter than params.EdgePeriods periods are returned as 0, since partial cells at the image border
+	and small margins are handled by error fixing and sampling.
+*/
+func nonPeriodicBorders(axis AxisResult, params CropParams) (int, int) {
+	combined := axis.Combined
+	period := axis.PixelGuess.Mean + axis.GridGuess.Mean
+	if period <= 0 || len(combined.Intervals) == 0 {
+		return 0, 0
+	}
+
+	is_known := func(i int) bool {
+		return combined.IntervalTypes[i] == types.INTERVAL_PIXEL || combined.IntervalTypes[i] == types.INTERVAL_GRID
+	}
+	// finds index of the first item of a run of MinRun known items, scanning from start in given direction
+	find_run := func(start, step int) int {
+		run := 0
+		for i := start; i >= 0 && i < len(combined.Intervals); i += step {
+			if !is_known(i) {
+				run = 0
+				continue
+			}
+			run += 1
+			if run >= params.MinRun {
+				return i - step * (run - 1)
+			}
+		}
+		return -1
+	}
+
+	first := find_run(0, 1)
+	last := find_run(len(combined.Intervals) - 1, -1)
+	if first < 0 || last < 0 || first > last {
+		return 0, 0
+	}
+
+	leading, trailing := 0, 0
+	for i := 0; i < first; i++ {
+		leading += int(combined.Intervals[i])
+	}
+	for i := last + 1; i < len(combined.Intervals); i++ {
+		trailing += int(combined.Intervals[i])
+	}
+	if float64(leading) < params.EdgePeriods * period {
+		leading = 0
+	}
+	if float64(trailing) < params.EdgePeriods * period {
+		trailing = 0
+	}
+	return leading, trailing
+}
+
+/*
+	Returns rectangle (in image coordinates) of the image without non-periodic borders found in axis results,
+	see nonPeriodicBorders and bordersDifferingFromArt. Rows describe X axis and Cols Y axis of the image.
+*/
+func cropNonPeriodicBorders(img *image.RGBA, rows, cols AxisResult, params CropParams) image.Rectangle {
+	rect := img.Rect
+	min_length := func(axis AxisResult) float64 {
+		return max(float64(params.MinSize), params.MinCells * (axis.PixelGuess.Mean + axis.GridGuess.Mean))
+	}
+
+	left, right := nonPeriodicBorders(rows, params)
+	left, right = bordersDifferingFromArt(img, rows.PixelGuess.Mean + rows.GridGuess.Mean, false, left, right, params)
+	top, bottom := nonPeriodicBorders(cols, params)
+	top, bottom = bordersDifferingFromArt(img, cols.PixelGuess.Mean + cols.GridGuess.Mean, true, top, bottom, params)
+	if float64(rect.Dx() - left - right) >= min_length(rows) {
+		rect.Min.X, rect.Max.X = rect.Min.X + left, rect.Max.X - right
+	}
+	if float64(rect.Dy() - top - bottom) >= min_length(cols) {
+		rect.Min.Y, rect.Max.Y = rect.Min.Y + top, rect.Max.Y - bottom
+	}
+	return rect
+}
+
+/*
+	Returns leading and trailing border lengths of an axis with borders that don't differ from the art set to 0.
+	A border differs from the art if the fraction of its colour edges aligned to the cell period (see alignedFraction)
+	is lower than params.AlignedRatio times the fraction of the art between both borders. Borders without colour edges
+	are kept, uniform ones were already removed by cropUniformBorders and flat art (gradients, sky) has edges
+	below tolerance.
+*/
+func bordersDifferingFromArt(
+	img *image.RGBA, period float64, vertical bool, leading, trailing int, params CropParams,
+) (int, int) {
+	if (leading == 0 && trailing == 0) || period < 1.0 {
+		return 0, 0
+	}
+
+	edge_counts := colorEdgeCounts(img, vertical, params.Tolerance)
+	length := len(edge_counts)
+	art_fraction := alignedFraction(edge_counts, leading, length - trailing, period)
+	differs := func(start, end int) bool {
+		fraction := alignedFraction(edge_counts, start, end, period)
+		return fraction >= 0 && art_fraction > 0 && fraction < params.AlignedRatio * art_fraction
+	}
+
+	if leading > 0 && !differs(0, leading) {
+		leading = 0
+	}
+	if trailing > 0 && !differs(length - trailing, length) {
+		trailing = 0
+	}
+	return leading, trailing
+}
+
+/*
+	Returns number of colour edges at every position of an axis (X axis, or Y axis if vertical is true).
+	Position p counts pixels whose colour differs by more than tolerance in any channel from their neighbour at p - 1.
+*/
+func colorEdgeCounts(img *image.RGBA, vertical bool, tolerance uint8) []int {
+	length, cross_length := img.Rect.Dx(), img.Rect.Dy()
+	if vertical {
+		length, cross_length = cross_length, length
+	}
+	pixel_at := func(position, cross int) [4]uint8 {
+		if vertical {
+			return common.PixelColor(img, cross, position)
+		}
+		return common.PixelColor(img, position, cross)
+	}
+
+	counts := make([]int, length)
+	for position := 1; position < length; position++ {
+		for cross := 0; cross < cross_length; cross++ {
+			if !common.ColorsClose(pixel_at(position - 1, cross), pixel_at(position, cross), tolerance) {
+				counts[position] += 1
+			}
+		}
+	}
+	return counts
+}
+
+/*
+	Returns fraction of colour edges at positions [start, end) that are aligned to the cell period, -1 if there are none.
+	The range is split into chunks of at most 4 periods, so that a slightly wrong period doesn't drift away from cell
+	borders. Edges of each chunk are folded by the period and the 3 pixel wide window of phases holding most of them
+	counts as aligned. Edges of pixel art almost all fall into the window, text and noise only by chance
+	(about 3 / period of them), periods below 4 can't tell them apart.
+*/
+func alignedFraction(edge_counts []int, start, end int, period float64) float64 {
+	start, end = max(start, 0), min(end, len(edge_counts))
+	chunk_length := max(int(4.0 * period), 1)
+	bins := make([]int, int(math.Ceil(period)))
+
+	total, aligned := 0, 0
+	for chunk := start; chunk < end; chunk += chunk_length {
+		clear(bins)
+		for position := chunk; position < min(chunk + chunk_length, end); position++ {
+			bins[int(math.Mod(float64(position), period))] += edge_counts[position]
+			total += edge_counts[position]
+		}
+		best := 0
+		for phase := range bins {
+			window := 0
+			for offset := -1; offset <= 1; offset++ {
+				window += bins[(phase + offset + len(bins)) % len(bins)]
+			}
+			best = max(best, window)
+		}
+		aligned += best
+	}
+	if total == 0 {
+		return -1.0
+	}
+	return min(float64(aligned) / float64(total), 1.0)
+}
@@ -0,0 +1,132 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+import (
+	"pixel_restoration/images"
+	"pixel_restoration/types"
+	"pixel_restoration/visualizations"
+)
+
+/*
+	Writes numbered images of all intermediate pipeline stages to debug_dir
+	and prints intermediate detection values to standard output.
+	All images are attempted even if some of them fail, returned error joins all failures.
+*/
+func saveDebugOutput(debug_dir string, state pipelineState) error {
+	var errs []error
+	save_rgba := func(name string, img *image.RGBA, err error) {
+		if err == nil {
+			err = images.RGBASaveToFile(debug_dir+"/"+name, img)
+		}
+		errs = append(errs, err)
+	}
+	save_gray := func(name string, img *image.Gray, err error) {
+		if err == nil {
+			err = images.GraySaveToFile(debug_dir+"/"+name, img)
+		}
+		errs = append(errs, err)
+	}
+
+	input_img := state.img_input
+	img_width, img_height := input_img.Rect.Dx(), input_img.Rect.Dy()
+
+	fmt.Println("Image size (width, height): ", img_width, img_height)
+	fmt.Println("Min peak height (rows, cols): ", state.min_peak_height_rows, state.min_peak_height_cols)
+
+	save_rgba("1_original.png", input_img, nil)
+	save_rgba("2_kuwaharad.png", state.img_preprocessed, nil)
+
+	edge_distances_cols_trans := images.GrayscaleGetTransposed(state.edge_distances_cols)
+	edges_sidebyside, err := visualizations.SideBySideGrayscale(
+		state.edge_distances_rows,
+		edge_distances_cols_trans,
+	)
+	save_gray("3_edges_sidebyside.png", edges_sidebyside, err)
+	save_gray("3_edges_rows.png", state.edge_distances_rows, nil)
+	save_gray("3_edges_cols.png", edge_distances_cols_trans, nil)
+
+	edge_cols_binary_trans := images.GrayscaleGetTransposed(state.edge_cols_binary)
+	edges_binary_sidebyside, err := visualizations.SideBySideGrayscale(
+		state.edge_rows_binary,
+		edge_cols_binary_trans,
+	)
+	save_gray("4_edges_binary_sidebyside.png", edges_binary_sidebyside, err)
+	save_gray("4_edges_binary_rows.png", state.edge_rows_binary, nil)
+	save_gray("4_edges_binary_cols.png", edge_cols_binary_trans, nil)
+
+	edge_cols_binary_cleaned_trans := images.GrayscaleGetTransposed(state.edge_cols_binary_cleaned)
+	edges_binary_sidebyside_cleaned, err := visualizations.SideBySideGrayscale(
+		state.edge_rows_binary_cleaned,
+		edge_cols_binary_cleaned_trans,
+	)
+	save_gray("5_edges_cleaned_sidebyside.png", edges_binary_sidebyside_cleaned, err)
+	save_gray("6_edges_cleaned_rows.png", state.edge_rows_binary_cleaned, nil)
+	save_gray("7_edges_cleaned_cols.png", edge_cols_binary_cleaned_trans, nil)
+
+	cutout_image, err := visualizations.ImageWithDrawnGridlinesSimple(
+		input_img,
+		[2][]int{state.most_frequent_cols, state.most_frequent_rows},
+		[4]uint8{255, 0, 255, 255},
+	)
+	save_rgba("8_cutout_base.png", cutout_image, err)
+
+	cutout_image_big, err := visualizations.ImageWithDrawnGridlinesAdvanced(
+		input_img,
+		[2][]int{state.most_frequent_cols, state.most_frequent_rows},
+		[4]uint8{255, 0, 255, 255},
+	)
+	save_rgba("8_cutout_advanced.png", cutout_image_big, err)
+
+	fmt.Println("Rows\n", state.most_frequent_rows)
+	fmt.Println("Cols\n", state.most_frequent_cols)
+
+	fmt.Println("Interval rows\n", state.rows_intervals.Intervals)
+	fmt.Println("Interval cols\n", state.cols_intervals.Intervals)
+
+	fmt.Printf("ROWS:\n     Pixel Guess: %-v\n     Grid guess: %-v\n", state.rows.PixelGuess, state.rows.GridGuess)
+	fmt.Printf("COLS:\n     Pixel Guess: %-v\n     Grid guess: %-v\n", state.cols.PixelGuess, state.cols.GridGuess)
+	fmt.Printf("Guess scores (rows, cols): %+v %+v\n", state.rows.Scores, state.cols.Scores)
+	fmt.Printf("Cleanup changed pixels (rows, cols): %+v %+v\n", state.cleanup_changed_rows, state.cleanup_changed_cols)
+
+	unknowns_image, err := visualizations.ImageWithDrawnCutoutSimpleWithZeros(
+		input_img,
+		[2]types.CombinedList{state.cols.Combined, state.rows.Combined},
+		[4]uint8{0, 0, 255, 255},
+		[4]uint8{255, 0, 255, 255},
+	)
+	save_rgba("9_with_unknowns.png", unknowns_image, err)
+
+	unknowns_image_big, err := visualizations.ImageWithDrawnCombinedListAdvanced(
+		input_img,
+		[2]types.CombinedList{state.cols.Combined, state.rows.Combined},
+		[4]uint8{0, 0, 255, 255},
+		[4]uint8{255, 0, 255, 255},
+	)
+	save_rgba("10_with_unknowns_advanced.png", unknowns_image_big, err)
+
+	fixed_image, err := visualizations.ImageWithDrawnCutoutSimpleWithZeros(
+		input_img,
+		[2]types.CombinedList{state.cols.Fixed, state.rows.Fixed},
+		[4]uint8{0, 0, 255, 255},
+		[4]uint8{255, 0, 255, 255},
+	)
+	save_rgba("11_error_fixed.png", fixed_image, err)
+
+	fixed_image_big, err := visualizations.ImageWithDrawnCombinedListAdvanced(
+		input_img,
+		[2]types.CombinedList{state.cols.Fixed, state.rows.Fixed},
+		[4]uint8{0, 0, 255, 255},
+		[4]uint8{255, 0, 255, 255},
+	)
+	save_rgba("12_error_fixed_advanced.png", fixed_image_big, err)
+
+	fmt.Println("Restored image size (width, height): ", state.restored.Rect.Dx(), state.restored.Rect.Dy())
+	save_rgba("13_restored.png", state.restored, nil)
+
+	return errors.Join(errs...)
+}
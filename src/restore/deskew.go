@@ -0,0 +1,62 @@
+package restore
+
+import (
+	"image"
+	"math"
+)
+
+import (
+	"pixel_restoration/contrast"
+	"pixel_restoration/images"
+)
+
+/*
+	DeskewParams describe detection and correction of grids rotated by a few degrees,
+	for example photos of screens or slightly rotated screenshots.
+
+	Enabled: bool
+		If false, the image is processed as it is.
+	Hough:
+		Parameters of rotation detection, see contrast.DetectGridRotation
+	MinAngle: float64
+		Detected rotations smaller than this many degrees are ignored, since resampling blurs edges more than they are tilted
+	MinStrength: float64
+		Detected rotation is applied only if its Hough vote concentration is at least this many times the one at 0 degrees
+	Interpolation: uint8
+		One of images.INTERPOLATION_ constants used to rotate the image
+*/
+type DeskewParams struct {
+	Enabled bool
+	Hough contrast.HoughParams
+	MinAngle float64
+	MinStrength float64
+	Interpolation uint8
+}
+
+func GetBaseDeskewParams() DeskewParams {
+	return DeskewParams{
+		Enabled: false,
+		Hough: contrast.GetBaseHoughParams(),
+		MinAngle: 0.2,
+		MinStrength: 1.2,
+		Interpolation: images.INTERPOLATION_BILINEAR,
+	}
+}
+
+/*
+	Detects rotation of the grid on binary edge maps of the input image and rotates the image back.
+	Returns the input image itself and 0 if the detected rotation is too small or too weak.
+*/
+func deskewImage(input_img *image.RGBA, options Options) (*image.RGBA, float64, error) {
+	var state pipelineState
+	if err := detectEdgeMaps(&state, input_img, options); err != nil {
+		return input_img, 0, err
+	}
+
+	params := options.Deskew
+	angle, strength := contrast.DetectGridRotation(state.edge_rows_binary_cleaned, state.edge_cols_binary_cleaned, params.Hough)
+	if math.Abs(angle) < params.MinAngle || strength < params.MinStrength {
+		return input_img, 0, nil
+	}
+	return images.ImageGetRotated(input_img, -angle, params.Interpolation), angle, nil
+}
@@ -0,0 +1,29 @@
+package restore
+
+import (
+	"errors"
+)
+
+import (
+	"pixel_restoration/common"
+)
+
+/*
+	Errors returned by Restore, compare them with errors.Is.
+	All of them except ErrDebugOutput mean that no result was produced,
+	see common package for description of the shared ones.
+
+	ErrDebugOutput:
+		Restoration succeeded but some debug images could not be written, the result is still returned
+*/
+var (
+	ErrTooFewEdges = common.ErrTooFewEdges
+	ErrAxisMismatch = common.ErrAxisMismatch
+	ErrInvalidUpscaleParams = common.ErrInvalidUpscaleParams
+	ErrImageTooSmall = common.ErrImageTooSmall
+	ErrIndexOutOfRange = common.ErrIndexOutOfRange
+	ErrInvalidFilterParams = common.ErrInvalidFilterParams
+	ErrInvalidLattice = common.ErrInvalidLattice
+	ErrInternal = common.ErrInternal
+	ErrDebugOutput = errors.New("failed to write debug output")
+)
@@ -0,0 +1,107 @@
+package restore
+
+import (
+	"math"
+)
+
+import (
+	"pixel_restoration/gridlines"
+	"pixel_restoration/types"
+)
+
+/*
+	Defines a set of uint8 constants used to select how pixel and gridline sizes are estimated
+	ESTIMATOR_HISTOGRAM:
+		interval histograms of gridlines.GuessGridlineParameters only
+	ESTIMATOR_SPECTRAL:
+		period found by gridlines.EstimatePeriodSpectral replaces the histogram guess whenever it is found
+	ESTIMATOR_CROSS_CHECK:
+		histogram guess is kept unless its period disagrees with the spectral one by more than CrossCheckTolerance
+*/
+const (
+	ESTIMATOR_HISTOGRAM uint8 = iota
+	ESTIMATOR_SPECTRAL uint8 = iota
+	ESTIMATOR_CROSS_CHECK uint8 = iota
+)
+
+var estimator_names = [3]string{"histogram", "spectral", "cross-check"}
+
+/*
+	Returns short name of an ESTIMATOR_ constant, used in reports and command line flags
+*/
+func EstimatorName(estimator uint8) string {
+	if int(estimator) >= len(estimator_names) {
+		return "unknown"
+	}
+	return estimator_names[estimator]
+}
+
+/*
+	Returns ESTIMATOR_ constant with given name and true, or false if no estimator has that name
+*/
+func EstimatorFromName(name string) (uint8, bool) {
+	for estimator, estimator_name := range estimator_names {
+		if estimator_name == name {
+			return uint8(estimator), true
+		}
+	}
+	return 0, false
+}
+
+/*
+	Mode: uint8
+		One of ESTIMATOR_ constants
+	Spectral:
+		Parameters of the spectral estimator, see gridlines.SpectralParams
+	CrossCheckTolerance: float64
+		Largest allowed relative difference of histogram and spectral periods in ESTIMATOR_CROSS_CHECK mode
+*/
+type EstimatorParams struct {
+	Mode uint8
+	Spectral gridlines.SpectralParams
+	CrossCheckTolerance float64
+}
+
+func GetBaseEstimatorParams() EstimatorParams {
+	return EstimatorParams{
+		Mode: ESTIMATOR_HISTOGRAM,
+		Spectral: gridlines.GetBaseSpectralParams(),
+		CrossCheckTolerance: 0.15,
+	}
+}
+
+/*
+	Recognises shaded cells on edge counts and selected edges of an axis,
+	folding them by the period found by the spectral estimator
+*/
+func detectCellStyle(edge_counts []uint, edges []int, options Options) gridlines.CellStyle {
+	estimate := gridlines.EstimatePeriodSpectral(edge_counts, options.Estimator.Spectral)
+	return gridlines.DetectCellStyle(edge_counts, edges, estimate.Period, options.CellStyle)
+}
+
+/*
+	Runs the spectral estimator on edge counts of an axis if the mode needs it and replaces pixel and gridline guesses
+	of the axis with the spectral ones when the mode says so. Scores of the axis always come from the histogram guess.
+*/
+func applySpectralEstimate(axis *AxisResult, edge_counts []uint, intervals types.IntervalList, params EstimatorParams) {
+	axis.Estimator = ESTIMATOR_HISTOGRAM
+	if params.Mode == ESTIMATOR_HISTOGRAM {
+		return
+	}
+
+	axis.Spectral = gridlines.EstimatePeriodSpectral(edge_counts, params.Spectral)
+	if axis.Spectral.Period <= 0 {
+		return
+	}
+
+	if params.Mode == ESTIMATOR_CROSS_CHECK {
+		histogram_period := axis.PixelGuess.Mean + axis.GridGuess.Mean
+		difference := math.Abs(histogram_period - axis.Spectral.Period) / axis.Spectral.Period
+		if difference <= params.CrossCheckTolerance {
+			return
+		}
+	}
+
+	axis.PixelGuess, axis.GridGuess = gridlines.GuessFromPeriod(axis.Spectral.Period, axis.GridGuess, intervals)
+	axis.Estimator = ESTIMATOR_SPECTRAL
+}
@@ -0,0 +1,394 @@
+package restore
+
+import (
+	"image"
+	"math"
+)
+
+import (
+	"pixel_restoration/contrast"
+	"pixel_restoration/gridlines"
+	"pixel_restoration/images"
+	"pixel_restoration/types"
+)
+
+/*
+	Defines a set of pipeline variants that can produce the final result
+*/
+const (
+	STRATEGY_BASE uint8 = iota
+	STRATEGY_NO_KUWAHARA uint8 = iota
+	STRATEGY_AGGRESSIVE_PEAKS uint8 = iota
+	STRATEGY_DOWNSCALE uint8 = iota
+)
+
+var strategy_names = [4]string{"base", "no_kuwahara", "aggressive_peaks", "downscale"}
+
+/*
+	Returns short name of a STRATEGY_ constant, used in reports
+*/
+func StrategyName(strategy uint8) string {
+	if int(strategy) >= len(strategy_names) {
+		return "unknown"
+	}
+	return strategy_names[strategy]
+}
+
+/*
+	FallbackParams describe when variants of the pipeline are re-run after the base run.
+
+	Enabled: bool
+		If false, only the base pipeline is run.
+	TinyPixelLimit: float64
+		If guessed pixel size of either axis is at most this value and no gridlines were found,
+		the pipeline is re-run without Kuwahara filter, which tends to blur away 1 and 2 pixel wide art pixels.
+	UnknownPeriods: float64
+		Unknown sections at least this many pixel+grid periods long are re-detected with more aggressive peak finder.
+	AggressiveFactor: float64
+		Multiplier (0.0 - 1.0) of min peak height and most frequent cutoff used by the aggressive peak finder.
+	AxisMismatchRatio: float64
+		If larger pixel size guess is more than this many times the smaller one,
+		the image is downscaled by the smaller guess and the pipeline is run again.
+*/
+type FallbackParams struct {
+	Enabled bool
+	TinyPixelLimit float64
+	UnknownPeriods float64
+	AggressiveFactor float64
+	AxisMismatchRatio float64
+}
+
+func GetBaseFallbackParams() FallbackParams {
+	return FallbackParams{
+		Enabled: true,
+		TinyPixelLimit: 2.5,
+		UnknownPeriods: 4.0,
+		AggressiveFactor: 0.5,
+		AxisMismatchRatio: 1.5,
+	}
+}
+
+/*
+	Runs the base pipeline and then re-runs its variants on known edge cases, in the following order:
+
+	1. Base run failed or guessed 1/0 or 2/0 <pixel/grid> on any axis: re-run without Kuwahara filter.
+	2. Large unknown sections are present: re-detect edges inside of them with more aggressive peak finder.
+	   The new edges are kept only if they reduce total length of unknown sections.
+	3. Pixel size guesses of both axes differ more than AxisMismatchRatio: downscale the image by the smaller guess
+	   and run the pipeline again without Kuwahara filter. The new result is kept only if its axes agree.
+
+	Each accepted variant becomes the base for the next check, Strategy of the result is the last accepted one.
+	Returned state belongs to the run that produced the result and is used for debug output.
+*/
+func restoreWithFallbacks(input_img *image.RGBA, options Options) (Result, pipelineState, error) {
+	params := options.Fallback
+	attempts := []uint8{STRATEGY_BASE}
+	strategy := STRATEGY_BASE
+
+	state, err := runPipeline(input_img, options)
+
+	if options.KuwaharaRadius >= 1 && (err != nil || isTinyGuess(state.rows, params) || isTinyGuess(state.cols, params)) {
+		attempts = append(attempts, STRATEGY_NO_KUWAHARA)
+		no_kuwahara_options := options
+		no_kuwahara_options.KuwaharaRadius = 0
+		retry_state, retry_err := runPipeline(input_img, no_kuwahara_options)
+		if retry_err == nil {
+			state, err = retry_state, nil
+			strategy = STRATEGY_NO_KUWAHARA
+		}
+	}
+	if err != nil {
+		return Result{}, state, err
+	}
+
+	if hasLargeUnknownSections(state.rows, params) || hasLargeUnknownSections(state.cols, params) {
+		attempts = append(attempts, STRATEGY_AGGRESSIVE_PEAKS)
+		if redetectUnknownSections(&state, options) {
+			strategy = STRATEGY_AGGRESSIVE_PEAKS
+		}
+	}
+
+	result := resultFromState(state, strategy)
+
+	if axisMismatch(state.rows, state.cols) > params.AxisMismatchRatio {
+		attempts = append(attempts, STRATEGY_DOWNSCALE)
+		downscaled_result, downscaled_state, ok := restoreDownscaled(input_img, state, options)
+		if ok {
+			result, state = downscaled_result, downscaled_state
+			result.Strategy = STRATEGY_DOWNSCALE
+		}
+	}
+
+	result.Attempts = attempts
+	return result, state, nil
+}
+
+/*
+	Returns true if axis guess is 1/0 or 2/0 <pixel/grid> (or smaller than TinyPixelLimit in general)
+*/
+func isTinyGuess(axis AxisResult, params FallbackParams) bool {
+	return axis.PixelGuess.Mean <= params.TinyPixelLimit && axis.GridGuess.Bounds[1] == 0
+}
+
+/*
+	Returns ratio of larger to smaller pixel size guess of both axes, infinity if either guess is 0
+*/
+func axisMismatch(rows, cols AxisResult) float64 {
+	smaller := min(rows.PixelGuess.Mean, cols.PixelGuess.Mean)
+	larger := max(rows.PixelGuess.Mean, cols.PixelGuess.Mean)
+	if smaller <= 0 {
+		return math.Inf(1)
+	}
+	return larger / smaller
+}
+
+/*
+	Returns [start, end) positions of unknown items of combined list that are at least min_length long
+*/
+func unknownSections(combined types.CombinedList, min_length float64) [][2]int {
+	sections := make([][2]int, 0)
+	position := 0
+	for i, interval := range combined.Intervals {
+		length := int(interval)
+		if combined.IntervalTypes[i] == types.INTERVAL_UNKNOWN && float64(length) >= min_length {
+			sections = append(sections, [2]int{position, position + length})
+		}
+		position += length
+	}
+	return sections
+}
+
+/*
+	Returns total length of unknown items of combined list, first and last (border) items are not counted
+*/
+func unknownLength(combined types.CombinedList) int {
+	total := 0
+	for i := 1; i < len(combined.Intervals) - 1; i++ {
+		if combined.IntervalTypes[i] == types.INTERVAL_UNKNOWN {
+			total += int(combined.Intervals[i])
+		}
+	}
+	return total
+}
+
+func largeUnknownLength(axis AxisResult, params FallbackParams) float64 {
+	return params.UnknownPeriods * (axis.PixelGuess.Mean + axis.GridGuess.Mean)
+}
+
+func hasLargeUnknownSections(axis AxisResult, params FallbackParams) bool {
+	return len(unknownSections(axis.Combined, largeUnknownLength(axis, params))) > 0
+}
+
+/*
+	Re-detects edges inside of large unknown sections of both axes with lowered min peak height and most frequent cutoff.
+	Axes whose total unknown length decreased are replaced in the state and the restored image is sampled again.
+	Returns true if any axis was replaced. If sampling with replaced axes fails, the state is left unchanged.
+*/
+func redetectUnknownSections(state *pipelineState, options Options) bool {
+	original := *state
+	img_width, img_height := state.img_input.Rect.Dx(), state.img_input.Rect.Dy()
+
+	rows_changed := redetectAxis(
+		&state.rows, &state.most_frequent_rows, &state.rows_intervals,
+		state.img_preprocessed, state.edge_distances_rows, false, state.min_peak_height_rows, img_width, options,
+	)
+	cols_changed := redetectAxis(
+		&state.cols, &state.most_frequent_cols, &state.cols_intervals,
+		state.img_preprocessed, state.edge_distances_cols, true, state.min_peak_height_cols, img_height, options,
+	)
+
+	if !rows_changed && !cols_changed {
+		return false
+	}
+	// merged axes were guessed independently, so both are coupled again and replaced guesses are finished anew
+	joint_changed := applyJointGuess(&state.rows, &state.cols, state.rows_intervals, state.cols_intervals, options)
+	if joint_changed[0] && finishAxis(&state.rows, state.most_frequent_rows, state.rows_intervals, options) != nil {
+		*state = original
+		return false
+	}
+	if joint_changed[1] && finishAxis(&state.cols, state.most_frequent_cols, state.cols_intervals, options) != nil {
+		*state = original
+		return false
+	}
+	if rows_changed || joint_changed[0] {
+		detectMajorGridByColor(&state.rows, state.img_input, false, options)
+	}
+	if cols_changed || joint_changed[1] {
+		detectMajorGridByColor(&state.cols, state.img_input, true, options)
+	}
+	if err := sampleRestoredImage(state, options); err != nil {
+		*state = original
+		return false
+	}
+	return true
+}
+
+/*
+	Runs aggressive edge detection on a single axis, edges inside of large unknown sections are replaced by the new ones.
+	Axis, edges and intervals are replaced in place only if total unknown length decreased.
+*/
+func redetectAxis(
+	axis *AxisResult, edges *[]int, intervals *types.IntervalList,
+	img *image.RGBA, distances *image.Gray, vertical bool, min_peak_height uint8, dim_length int, options Options,
+) bool {
+	params := options.Fallback
+	sections := unknownSections(axis.Combined, largeUnknownLength(*axis, params))
+	if len(sections) == 0 {
+		return false
+	}
+
+	aggressive_height := uint8(max(float64(min_peak_height) * params.AggressiveFactor + 0.5, 1.0))
+	edges_binary := contrast.ThresholdAdaptive(distances, aggressive_height, options.PeakHeight)
+	edges_binary_cleaned, _ := contrast.CleanupEdgeArtifacts(edges_binary, options.Cleanup)
+	if options.Peaks.Enabled {
+		edges_binary_cleaned = contrast.SuppressNonMaxima(
+			img, distances, edges_binary_cleaned, vertical, options.ColorMetric, options.Peaks,
+		)
+	}
+	edge_counts := contrast.EdgeCountsWithMode(edges_binary_cleaned, options.MostFrequent.CountMode)
+
+	most_frequent_params := options.MostFrequent
+	most_frequent_params.CutoffMultiplier *= float32(params.AggressiveFactor)
+	aggressive_edges := gridlines.FilterShadingEdges(
+		contrast.SelectMostFrequent(edge_counts, most_frequent_params), axis.CellStyle,
+	)
+
+	merged_edges := mergeEdgesInSections(*edges, aggressive_edges, sections)
+	merged_intervals := types.IntervalListFromSortedEdgeIndexes(merged_edges, dim_length)
+	merged_axis, err := detectAxis(merged_edges, edge_counts, merged_intervals, options)
+	if err != nil || unknownLength(merged_axis.Combined) >= unknownLength(axis.Combined) {
+		return false
+	}
+	merged_axis.CellStyle = axis.CellStyle
+
+	*axis, *edges, *intervals = merged_axis, merged_edges, merged_intervals
+	return true
+}
+
+/*
+	Returns sorted edges, where edges strictly inside of any section come from <section_edges>
+	and all other edges come from <edges>. Both input slices must be sorted.
+*/
+func mergeEdgesInSections(edges, section_edges []int, sections [][2]int) []int {
+	inside := func(edge int) bool {
+		for _, section := range sections {
+			if section[0] < edge && edge < section[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	merged := make([]int, 0, len(edges) + len(section_edges))
+	i, j := 0, 0
+	for i < len(edges) || j < len(section_edges) {
+		if j >= len(section_edges) || (i < len(edges) && edges[i] <= section_edges[j]) {
+			if !inside(edges[i]) {
+				merged = append(merged, edges[i])
+			}
+			i += 1
+		} else {
+			if inside(section_edges[j]) {
+				merged = append(merged, section_edges[j])
+			}
+			j += 1
+		}
+	}
+	return merged
+}
+
+/*
+	Downscales the image by the smaller pixel size guess of the state and runs the pipeline on it without Kuwahara filter.
+	Result is accepted only if pixel size guesses of the downscaled run agree within AxisMismatchRatio.
+	Restored image comes from the downscaled run, axis results are scaled back to input image coordinates,
+	so their interval lengths are only approximate, but they still sum to the input image size
+	and describe the same cells as the restored image.
+*/
+func restoreDownscaled(input_img *image.RGBA, state pipelineState, options Options) (Result, pipelineState, bool) {
+	factor := min(state.rows.PixelGuess.Mean, state.cols.PixelGuess.Mean)
+	// downscaling by 1 changes nothing
+	if factor < 2.0 {
+		return Result{}, state, false
+	}
+
+	new_width := int(math.Round(float64(input_img.Rect.Dx()) / factor))
+	new_height := int(math.Round(float64(input_img.Rect.Dy()) / factor))
+	if new_width < 3 || new_height < 3 {
+		return Result{}, state, false
+	}
+	downscaled := images.ImageGetResized(input_img, new_width, new_height, images.INTERPOLATION_BILINEAR)
+
+	downscaled_options := options
+	downscaled_options.KuwaharaRadius = 0
+	downscaled_state, err := runPipeline(downscaled, downscaled_options)
+	if err != nil || axisMismatch(downscaled_state.rows, downscaled_state.cols) > options.Fallback.AxisMismatchRatio {
+		return Result{}, state, false
+	}
+
+	result := resultFromState(downscaled_state, STRATEGY_DOWNSCALE)
+	result.Rows = scaleAxisResult(result.Rows, float64(input_img.Rect.Dx()) / float64(new_width))
+	result.Cols = scaleAxisResult(result.Cols, float64(input_img.Rect.Dy()) / float64(new_height))
+	return result, downscaled_state, true
+}
+
+/*
+	Returns a copy of axis result with guesses and interval lengths multiplied by factor.
+	Interval boundaries are scaled and rounded instead of lengths, so rounding errors don't add up
+	and scaled lists sum to their length multiplied by factor, rounded.
+*/
+func scaleAxisResult(axis AxisResult, factor float64) AxisResult {
+	scale_entry := func(entry types.IntervalRangeEntry) types.IntervalRangeEntry {
+		entry.Mean *= factor
+		entry.Bounds[0] = int(math.Floor(float64(entry.Bounds[0]) * factor))
+		entry.Bounds[1] = int(math.Ceil(float64(entry.Bounds[1]) * factor))
+		return entry
+	}
+	scale_list := func(list types.CombinedList) types.CombinedList {
+		scaled := types.CombinedList{
+			Intervals: make([]uint, len(list.Intervals)),
+			IntervalTypes: append([]uint8{}, list.IntervalTypes...),
+		}
+		var start, end uint = 0, 0
+		for i, interval := range list.Intervals {
+			end += interval
+			scaled_start := math.Round(float64(start) * factor)
+			scaled.Intervals[i] = uint(math.Round(float64(end) * factor) - scaled_start)
+			start = end
+		}
+		return scaled
+	}
+
+	return AxisResult{
+		PixelGuess: scale_entry(axis.PixelGuess),
+		GridGuess: scale_entry(axis.GridGuess),
+		Combined: scale_list(axis.Combined),
+		Fixed: scale_list(axis.Fixed),
+		Scores: axis.Scores,
+		Lattice: types.LatticeScaled(axis.Lattice, factor),
+		Spectral: gridlines.PeriodEstimate{
+			Period: axis.Spectral.Period * factor,
+			Strength: axis.Spectral.Strength,
+			Coherence: axis.Spectral.Coherence,
+		},
+		Estimator: axis.Estimator,
+		JointCorrected: axis.JointCorrected,
+		Major: gridlines.MajorGrid{
+			Bounds: [2]int{
+				int(math.Floor(float64(axis.Major.Bounds[0]) * factor)),
+				int(math.Ceil(float64(axis.Major.Bounds[1]) * factor)),
+			},
+			Mean: axis.Major.Mean * factor,
+			Period: axis.Major.Period,
+			Count: axis.Major.Count,
+			Regularity: axis.Major.Regularity,
+			First: int(math.Round(float64(axis.Major.First) * factor)),
+		},
+		CellStyle: gridlines.CellStyle{
+			Style: axis.CellStyle.Style,
+			Period: axis.CellStyle.Period * factor,
+			BandStart: axis.CellStyle.BandStart * factor,
+			BandWidth: axis.CellStyle.BandWidth * factor,
+			ShadingEdges: axis.CellStyle.ShadingEdges,
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package restore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"pixel_restoration/images"
+	"pixel_restoration/sampling"
+)
+
+// test runs from the package directory, test images are relative to the repository root
+const CLEAN_TEST_DIRECTORY string = "../../images/test_set_pixelarts_clean"
+
+/*
+	Runs the downscale strategy on clean test images and checks that axis results scaled back to input coordinates
+	sum to the input image size and have as many sampled cells as the restored image.
+*/
+func TestDownscaledAxesMatchImage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("corpus run skipped in short mode")
+	}
+
+	entries, err := os.ReadDir(CLEAN_TEST_DIRECTORY)
+	if err != nil {
+		t.Fatalf("cannot read clean test directory: %v", err)
+	}
+	options := GetBaseOptions()
+
+	accepted := 0
+	for _, entry := range entries {
+		img, err := images.RGBALoadFromFile(filepath.Join(CLEAN_TEST_DIRECTORY, entry.Name()))
+		if err != nil {
+			continue
+		}
+		state, err := runPipeline(img, options)
+		if err != nil {
+			continue
+		}
+		result, _, ok := restoreDownscaled(img, state, options)
+		if !ok {
+			continue
+		}
+		accepted += 1
+
+		axes := [2]struct {
+			name string
+			axis AxisResult
+			length, cells int
+		}{
+			{"rows", result.Rows, img.Rect.Dx(), result.Image.Rect.Dx()},
+			{"cols", result.Cols, img.Rect.Dy(), result.Image.Rect.Dy()},
+		}
+		for _, axis := range axes {
+			sum := 0
+			for _, interval := range axis.axis.Fixed.Intervals {
+				sum += int(interval)
+			}
+			// restored image is sampled from these cells, see sampling.SampleRestoredImage
+			pixels := len(sampling.GetPixelCellRanges(axis.axis.Fixed, options.Sampling.EdgeCellMinFraction))
+			if sum != axis.length {
+				t.Errorf("%s %s: scaled intervals sum to %d, image is %d long", entry.Name(), axis.name, sum, axis.length)
+			}
+			if pixels != axis.cells {
+				t.Errorf("%s %s: scaled axis has %d cells, restored image has %d", entry.Name(), axis.name, pixels, axis.cells)
+			}
+		}
+	}
+	if accepted == 0 {
+		t.Fatal("downscale strategy was not accepted for any clean image")
+	}
+	t.Logf("%d clean images restored by downscale strategy checked", accepted)
+}
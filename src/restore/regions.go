@@ -0,0 +1,418 @@
+package restore
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"slices"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/contrast"
+	"pixel_restoration/gridlines"
+)
+
+/*
+	RegionParams describe segmentation of an image into parts with different grids, see RestoreRegions.
+
+	BlockSize: int
+		Side of square blocks the image is split into, each block gets its own period estimate.
+		Blocks must contain at least 3 periods, so pixel sizes above BlockSize / 3 are not detected.
+	MinStrength: float64
+		Blocks whose period estimate on either axis has lower strength (see gridlines.PeriodEstimate) are background
+	PeriodTolerance: float64
+		Neighbouring blocks belong to the same region if their periods differ by at most this fraction on both axes
+	MinBlocks: int
+		Regions made of fewer blocks are dropped
+	TrimTolerance: uint8
+		Pixels within this difference of any channel from the background colour (most common colour of the image border)
+		are background when regions are fitted to the art they contain, see regionRects
+
+	Constraints:
+		BlockSize >= 8
+		0 <= PeriodTolerance < 1
+		MinBlocks >= 1
+*/
+type RegionParams struct {
+	BlockSize int
+	MinStrength float64
+	PeriodTolerance float64
+	MinBlocks int
+	TrimTolerance uint8
+}
+
+func GetBaseRegionParams() RegionParams {
+	return RegionParams{
+		BlockSize: 96,
+		MinStrength: 0.3,
+		PeriodTolerance: 0.15,
+		MinBlocks: 2,
+		TrimTolerance: 8,
+	}
+}
+
+/*
+	Result of restoring one region of the image.
+
+	Rect:
+		Part of the input image the region covers, in coordinates of the input image
+	Period: [2]float64
+		Median of block period estimates of the region along X and Y axis (pixel size + gridline width)
+	Result:
+		Result of Restore run on the region, valid only if Err is nil
+	Err:
+		Error returned by Restore for the region
+*/
+type RegionResult struct {
+	Rect image.Rectangle
+	Period [2]float64
+	Result Result
+	Err error
+}
+
+/*
+	RestoreRegions finds parts of the image with different grids (for example screenshots with pixel art elements
+	of several scales) and restores each of them separately.
+
+	Edge maps of the whole image are split into blocks of options.Regions.BlockSize and period of each block is estimated
+	with gridlines.EstimatePeriodSpectral on both axes. Neighbouring blocks with matching periods are joined into regions,
+	blocks without regular edges (flat background) don't belong to any region.
+	Region rectangles are fitted to connected non-background art inside their blocks (see regionRects)
+	and Restore is run on each rectangle as a sub-image, so results share pixels
+	with the input image and describe positions relative to Rect.Min. Debug output is not written for regions.
+	Bounding rectangles of differently shaped regions may overlap. Regions whose rectangle is shorter than 3 periods
+	on either axis (a few stray pixels of art assigned to a region of their own) are dropped.
+
+	Regions are sorted by their position (top to bottom, left to right).
+	Returns ErrImageTooSmall if image is smaller than one block, ErrInvalidFilterParams for invalid options
+	(see ValidateOptions) and ErrTooFewEdges if no region was found,
+	failures of individual regions are returned in RegionResult.Err.
+*/
+func RestoreRegions(input_img *image.RGBA, options Options) (regions []RegionResult, err error) {
+	if err := ValidateOptions(options); err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	params := options.Regions
+	if input_img == nil || input_img.Rect.Dx() < params.BlockSize || input_img.Rect.Dy() < params.BlockSize {
+		return nil, fmt.Errorf("restore: %w: regions need at least one block of %d pixels", ErrImageTooSmall, params.BlockSize)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			regions, err = nil, fmt.Errorf("restore: %w: %v", ErrInternal, recovered)
+		}
+	}()
+
+	var state pipelineState
+	if err := detectEdgeMaps(&state, input_img, options); err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	block_periods := estimateBlockPeriods(state, options)
+	components := connectedBlockRegions(block_periods, params)
+	if len(components) == 0 {
+		return nil, fmt.Errorf("restore: %w: no block has a regular grid", ErrTooFewEdges)
+	}
+
+	region_options := options
+	region_options.DebugDir = ""
+	rects := regionRects(input_img, components, params)
+	for region_id, blocks := range components {
+		x_periods, y_periods := make([]float64, len(blocks)), make([]float64, len(blocks))
+		for i, block := range blocks {
+			x_periods[i], y_periods[i] = block_periods[block[1]][block[0]][0], block_periods[block[1]][block[0]][1]
+		}
+
+		rect := rects[region_id].Add(input_img.Rect.Min)
+		region := RegionResult{Rect: rect, Period: [2]float64{medianFloat(x_periods), medianFloat(y_periods)}}
+		// stray art pixels can be the only art of a region, blocks need 3 periods to be estimated, so does the region
+		if float64(rect.Dx()) < 3 * region.Period[0] || float64(rect.Dy()) < 3 * region.Period[1] {
+			continue
+		}
+		region.Result, region.Err = Restore(input_img.SubImage(rect).(*image.RGBA), region_options)
+		regions = append(regions, region)
+	}
+
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("restore: %w: no region is large enough to hold its grid", ErrTooFewEdges)
+	}
+
+	slices.SortFunc(regions, func(a, b RegionResult) int {
+		if a.Rect.Min.Y != b.Rect.Min.Y {
+			return a.Rect.Min.Y - b.Rect.Min.Y
+		}
+		return a.Rect.Min.X - b.Rect.Min.X
+	})
+	return regions, nil
+}
+
+/*
+	Returns [X, Y] period of every block indexed as [block_y][block_x], zero period means background.
+	Blocks on the right and bottom border absorb the remainder of the image, so all blocks are at least BlockSize long.
+*/
+func estimateBlockPeriods(state pipelineState, options Options) [][][2]float64 {
+	params := options.Regions
+	width, height := state.img_input.Rect.Dx(), state.img_input.Rect.Dy()
+	blocks_x, blocks_y := width / params.BlockSize, height / params.BlockSize
+
+	periods := make([][][2]float64, blocks_y)
+	for block_y := range periods {
+		periods[block_y] = make([][2]float64, blocks_x)
+		for block_x := range periods[block_y] {
+			rect := blockRect([2]int{block_x, block_y}, params.BlockSize, width, height)
+
+			// rows map has the shape of the image, cols map is transposed
+			rows_block := state.edge_rows_binary_cleaned.SubImage(rect).(*image.Gray)
+			cols_block := state.edge_cols_binary_cleaned.SubImage(
+				image.Rect(rect.Min.Y, rect.Min.X, rect.Max.Y, rect.Max.X),
+			).(*image.Gray)
+
+			estimate_x := gridlines.EstimatePeriodSpectral(
+				contrast.EdgeCountsWithMode(rows_block, options.MostFrequent.CountMode), options.Estimator.Spectral,
+			)
+			estimate_y := gridlines.EstimatePeriodSpectral(
+				contrast.EdgeCountsWithMode(cols_block, options.MostFrequent.CountMode), options.Estimator.Spectral,
+			)
+			if estimate_x.Strength >= params.MinStrength && estimate_y.Strength >= params.MinStrength {
+				periods[block_y][block_x] = [2]float64{estimate_x.Period, estimate_y.Period}
+			}
+		}
+	}
+	return periods
+}
+
+/*
+	Returns rectangle of the block relative to image origin, last block of each axis extends to the image border
+*/
+func blockRect(block [2]int, block_size, width, height int) image.Rectangle {
+	blocks_x, blocks_y := width / block_size, height / block_size
+	rect := image.Rect(block[0] * block_size, block[1] * block_size, (block[0] + 1) * block_size, (block[1] + 1) * block_size)
+	if block[0] == blocks_x - 1 {
+		rect.Max.X = width
+	}
+	if block[1] == blocks_y - 1 {
+		rect.Max.Y = height
+	}
+	return rect
+}
+
+/*
+	Joins 4-neighbouring non-background blocks with matching periods into regions.
+	Returns [block_x, block_y] indexes of blocks of each region with at least params.MinBlocks blocks.
+*/
+func connectedBlockRegions(periods [][][2]float64, params RegionParams) [][][2]int {
+	if len(periods) == 0 {
+		return nil
+	}
+	blocks_y, blocks_x := len(periods), len(periods[0])
+	visited := make([][]bool, blocks_y)
+	for block_y := range visited {
+		visited[block_y] = make([]bool, blocks_x)
+	}
+
+	periods_match := func(a, b [2]float64) bool {
+		for axis := 0; axis < 2; axis++ {
+			if math.Abs(a[axis] - b[axis]) > params.PeriodTolerance * max(a[axis], b[axis]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	regions := make([][][2]int, 0, 4)
+	for start_y := 0; start_y < blocks_y; start_y++ {
+		for start_x := 0; start_x < blocks_x; start_x++ {
+			if visited[start_y][start_x] || periods[start_y][start_x][0] == 0 {
+				continue
+			}
+
+			region := [][2]int{{start_x, start_y}}
+			visited[start_y][start_x] = true
+			for i := 0; i < len(region); i++ {
+				current := region[i]
+				current_period := periods[current[1]][current[0]]
+				for _, step := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					x, y := current[0] + step[0], current[1] + step[1]
+					if x < 0 || y < 0 || x >= blocks_x || y >= blocks_y || visited[y][x] || periods[y][x][0] == 0 {
+						continue
+					}
+					if periods_match(current_period, periods[y][x]) {
+						visited[y][x] = true
+						region = append(region, [2]int{x, y})
+					}
+				}
+			}
+
+			if len(region) >= params.MinBlocks {
+				regions = append(regions, region)
+			}
+		}
+	}
+	return regions
+}
+
+/*
+	Connected part of the image that differs from the background colour.
+
+	Rect:
+		Bounding rectangle relative to image origin
+	BlockPixels:
+		Number of pixels of the component in each block, indexed by [block_x, block_y]
+*/
+type foregroundComponent struct {
+	Rect image.Rectangle
+	BlockPixels map[[2]int]int
+}
+
+/*
+	Returns the most common colour among border pixels of the image
+*/
+func borderBackgroundColor(img *image.RGBA) [4]uint8 {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	counts := make(map[[4]uint8]int)
+	var result [4]uint8
+	best := 0
+	count_pixel := func(x, y int) {
+		color := common.PixelColor(img, x, y)
+		counts[color] += 1
+		if counts[color] > best {
+			result, best = color, counts[color]
+		}
+	}
+	for x := 0; x < width; x++ {
+		count_pixel(x, 0)
+		count_pixel(x, height - 1)
+	}
+	for y := 1; y < height - 1; y++ {
+		count_pixel(0, y)
+		count_pixel(width - 1, y)
+	}
+	return result
+}
+
+/*
+	Labels 4-connected components of pixels further than tolerance from the background colour
+*/
+func foregroundComponents(img *image.RGBA, background [4]uint8, tolerance uint8, block_size int) []foregroundComponent {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	blocks_x, blocks_y := width / block_size, height / block_size
+	visited := make([]bool, width * height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			visited[y * width + x] = common.ColorsClose(common.PixelColor(img, x, y), background, tolerance)
+		}
+	}
+
+	components := make([]foregroundComponent, 0, 8)
+	stack := make([][2]int, 0, 1024)
+	for start := range visited {
+		if visited[start] {
+			continue
+		}
+		component := foregroundComponent{
+			Rect: image.Rect(start % width, start / width, start % width + 1, start / width + 1),
+			BlockPixels: make(map[[2]int]int),
+		}
+		visited[start] = true
+		stack = append(stack[:0], [2]int{start % width, start / width})
+		for len(stack) > 0 {
+			pixel := stack[len(stack) - 1]
+			stack = stack[:len(stack) - 1]
+			component.Rect = component.Rect.Union(image.Rect(pixel[0], pixel[1], pixel[0] + 1, pixel[1] + 1))
+			component.BlockPixels[[2]int{min(pixel[0] / block_size, blocks_x - 1), min(pixel[1] / block_size, blocks_y - 1)}] += 1
+
+			for _, step := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				x, y := pixel[0] + step[0], pixel[1] + step[1]
+				if x < 0 || y < 0 || x >= width || y >= height || visited[y * width + x] {
+					continue
+				}
+				visited[y * width + x] = true
+				stack = append(stack, [2]int{x, y})
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+/*
+	Computes rectangles (relative to image origin) of regions given as lists of their blocks.
+
+	Each foreground component is assigned to the region holding at least 3/4 of its pixels that lie in any region,
+	a region covers union of its components. This keeps art that pokes into a block of another region out of it.
+	Regions without components (for example images without flat background, where everything is one component)
+	cover their blocks, with border lines of background colour trimmed.
+*/
+func regionRects(img *image.RGBA, regions [][][2]int, params RegionParams) []image.Rectangle {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	block_regions := make(map[[2]int]int)
+	for region_id, blocks := range regions {
+		for _, block := range blocks {
+			block_regions[block] = region_id
+		}
+	}
+
+	background := borderBackgroundColor(img)
+	rects := make([]image.Rectangle, len(regions))
+	for _, component := range foregroundComponents(img, background, params.TrimTolerance, params.BlockSize) {
+		region_pixels := make([]int, len(regions))
+		total := 0
+		for block, pixels := range component.BlockPixels {
+			if region_id, ok := block_regions[block]; ok {
+				region_pixels[region_id] += pixels
+				total += pixels
+			}
+		}
+		for region_id, pixels := range region_pixels {
+			if total > 0 && pixels * 4 >= total * 3 {
+				rects[region_id] = rects[region_id].Union(component.Rect)
+			}
+		}
+	}
+
+	for region_id, blocks := range regions {
+		if !rects[region_id].Empty() {
+			continue
+		}
+		for _, block := range blocks {
+			rects[region_id] = rects[region_id].Union(blockRect(block, params.BlockSize, width, height))
+		}
+		rects[region_id] = trimBackgroundBorder(img, rects[region_id], background, params.TrimTolerance)
+	}
+	return rects
+}
+
+/*
+	Shrinks rectangle (relative to image origin) while its outermost row or column is of background colour
+*/
+func trimBackgroundBorder(img *image.RGBA, rect image.Rectangle, background [4]uint8, tolerance uint8) image.Rectangle {
+	line_is_background := func(line image.Rectangle) bool {
+		for y := line.Min.Y; y < line.Max.Y; y++ {
+			for x := line.Min.X; x < line.Max.X; x++ {
+				if !common.ColorsClose(common.PixelColor(img, x, y), background, tolerance) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for rect.Dy() > 1 && line_is_background(image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y + 1)) {
+		rect.Min.Y += 1
+	}
+	for rect.Dy() > 1 && line_is_background(image.Rect(rect.Min.X, rect.Max.Y - 1, rect.Max.X, rect.Max.Y)) {
+		rect.Max.Y -= 1
+	}
+	for rect.Dx() > 1 && line_is_background(image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X + 1, rect.Max.Y)) {
+		rect.Min.X += 1
+	}
+	for rect.Dx() > 1 && line_is_background(image.Rect(rect.Max.X - 1, rect.Min.Y, rect.Max.X, rect.Max.Y)) {
+		rect.Max.X -= 1
+	}
+	return rect
+}
+
+func medianFloat(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[len(sorted) / 2]
+}
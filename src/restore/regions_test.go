@@ -0,0 +1,35 @@
+package restore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"pixel_restoration/images"
+)
+
+/*
+	A stray art pixel in the corner of a single-grid image used to become a region of its own, 1 pixel large,
+	next to the region of the whole image. Only the whole image region is expected.
+*/
+func TestRestoreRegionsDropsStrayPixels(t *testing.T) {
+	path := filepath.Join("..", "..", "images", "test_set_pixelarts_grided", "GRIDED_1_14_woman.png")
+	img, err := images.RGBALoadFromFile(path)
+	if err != nil {
+		t.Fatalf("cannot load test image: %v", err)
+	}
+
+	regions, err := RestoreRegions(img, GetBaseOptions())
+	if err != nil {
+		t.Fatalf("RestoreRegions failed: %v", err)
+	}
+	for _, region := range regions {
+		if region.Err != nil {
+			t.Errorf("region %v (period %.3f %.3f) failed: %v", region.Rect, region.Period[0], region.Period[1], region.Err)
+		}
+	}
+	if len(regions) != 1 {
+		t.Errorf("expected 1 region, got %d", len(regions))
+	}
+}
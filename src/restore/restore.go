@@ -0,0 +1,568 @@
+package restore
+
+import (
+	"fmt"
+	"image"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/contrast"
+	"pixel_restoration/gridlines"
+	"pixel_restoration/images/kuwahara"
+	"pixel_restoration/sampling"
+	"pixel_restoration/types"
+)
+
+/*
+	Options gathers all parameters of the restoration pipeline.
+
+	KuwaharaRadius: int
+		Radius of Kuwahara filter applied before edge detection. Value lower than 1 disables the filter.
+	KuwaharaSigma: float32
+		Sigma of gaussian kernel used by Kuwahara filter. Non positive value makes it computed from radius.
+	ColorMetric: uint8
+		One of contrast.METRIC_ constants, measures difference of adjacent pixels. Perceptual metrics separate
+		pastel and low-contrast palettes that are close in RGB, see contrast.CalculatePixelEdgeDistancesWithMetric.
+	PeakHeight, MostFrequent:
+		Parameters of edge detection stages, see contrast package for more info.
+	Cleanup:
+		Parameters of morphological cleanup of binary edge maps, see contrast.CleanupEdgeArtifacts.
+	Peaks:
+		Parameters of peak detection run on cleaned binary edge maps, see contrast.SuppressNonMaxima.
+	Sampling:
+		Parameters of the final sampling stage, see sampling package for more info.
+	Estimator:
+		Selects how pixel and gridline sizes are estimated, see EstimatorParams.
+	Joint:
+		Selects how guesses of both axes are coupled, see gridlines.GuessJoint.
+	MajorGrid:
+		Parameters of secondary gridline detection, see gridlines.DetectMajorGrid.
+	DetectMajorGrid: bool
+		If true, thicker gridlines repeating every few cells are marked as grid before unknown sections are fixed.
+	CellStyle:
+		Parameters of shaded cell recognition, see gridlines.DetectCellStyle.
+	DetectCellStyle: bool
+		If true, edges of highlights and shadows repeated inside every cell (beveled tiles, beads, bricks) are merged
+		into a single band around the cell border before intervals are built, so the band is sampled like a gridline.
+		Disabled by default, since it runs the spectral estimator on both axes regardless of Estimator.
+	Lattice:
+		Parameters of continuous lattice fitting, see gridlines.FitLattice.
+	SampleLattice: bool
+		If true, restored image is sampled using fitted lattices instead of fixed combined lists,
+		which keeps cells of non-integer pixel sizes (for example 10.5) centred across the whole image.
+	Fallback:
+		Parameters of automatic re-runs on edge cases, see FallbackParams.
+	Deskew:
+		Parameters of rotated grid detection run before the pipeline, see DeskewParams.
+	Regions:
+		Parameters of segmentation used by RestoreRegions, see RegionParams.
+	Crop:
+		Parameters of margin and border detection, the grid is detected only inside the art, see CropParams.
+	DebugDir: string
+		If not empty, numbered images of all intermediate stages of the final run are written to this directory
+		and intermediate values are printed to standard output.
+*/
+type Options struct {
+	KuwaharaRadius int
+	KuwaharaSigma float32
+	ColorMetric uint8
+	PeakHeight contrast.PeakHeightParams
+	Cleanup contrast.CleanupParams
+	Peaks contrast.PeakParams
+	MostFrequent contrast.MostFrequentParams
+	Sampling sampling.SamplingParams
+	Estimator EstimatorParams
+	Joint gridlines.JointParams
+	MajorGrid gridlines.MajorGridParams
+	DetectMajorGrid bool
+	CellStyle gridlines.CellStyleParams
+	DetectCellStyle bool
+	Lattice gridlines.LatticeParams
+	SampleLattice bool
+	Fallback FallbackParams
+	Deskew DeskewParams
+	Regions RegionParams
+	Crop CropParams
+	DebugDir string
+}
+
+func GetBaseOptions() Options {
+	return Options{
+		KuwaharaRadius: 2,
+		KuwaharaSigma: 1.5,
+		ColorMetric: contrast.METRIC_RGB,
+		PeakHeight: contrast.GetBasePeakHeightParams(),
+		Cleanup: contrast.GetBaseCleanupParams(),
+		Peaks: contrast.GetBasePeakParams(),
+		MostFrequent: contrast.GetBaseMostFrequentParams(),
+		Sampling: sampling.GetBaseSamplingParams(),
+		Estimator: GetBaseEstimatorParams(),
+		Joint: gridlines.GetBaseJointParams(),
+		MajorGrid: gridlines.GetBaseMajorGridParams(),
+		DetectMajorGrid: true,
+		CellStyle: gridlines.GetBaseCellStyleParams(),
+		DetectCellStyle: false,
+		Lattice: gridlines.GetBaseLatticeParams(),
+		SampleLattice: false,
+		Fallback: GetBaseFallbackParams(),
+		Deskew: GetBaseDeskewParams(),
+		Regions: GetBaseRegionParams(),
+		Crop: GetBaseCropParams(),
+		DebugDir: "",
+	}
+}
+
+/*
+	AxisResult holds detection output for one axis of the image.
+
+	PixelGuess, GridGuess:
+		Interval range entries guessed by gridlines.GuessGridlineParameters or built from the spectral period
+	Combined:
+		Combined list before unknown sections were fixed, major gridlines are already marked as grid
+	Fixed:
+		Combined list after unknown sections were fixed, contains only pixel and grid items
+	Scores:
+		Runlength scores that decided the guess, see gridlines.GuessScores
+	Lattice:
+		Continuous period and phase fitted to detected edges, see types.Lattice
+	Spectral:
+		Period found by gridlines.EstimatePeriodSpectral, zero if ESTIMATOR_HISTOGRAM was used
+	Estimator: uint8
+		ESTIMATOR_HISTOGRAM or ESTIMATOR_SPECTRAL, the estimator whose guess was used
+	JointCorrected: bool
+		True if the guess of this axis was replaced by the other axis' hypothesis, see gridlines.GuessJoint
+	Major:
+		Secondary gridlines found by gridlines.DetectMajorGrid, Period is 0 if there are none
+	CellStyle:
+		Shading found by gridlines.DetectCellStyle, shading edges were removed from intervals if Style is CELL_SHADED
+*/
+type AxisResult struct {
+	PixelGuess types.IntervalRangeEntry
+	GridGuess types.IntervalRangeEntry
+	Combined types.CombinedList
+	Fixed types.CombinedList
+	Scores gridlines.GuessScores
+	Lattice types.Lattice
+	Spectral gridlines.PeriodEstimate
+	Estimator uint8
+	JointCorrected bool
+	Major gridlines.MajorGrid
+	CellStyle gridlines.CellStyle
+}
+
+/*
+	Result of the restoration pipeline.
+
+	Image:
+		Restored image, one pixel per art pixel
+	Rotation: float64
+		Angle in degrees the grid was rotated by (see images.ImageGetRotated), 0 if the image was not deskewed.
+		Axis results describe the deskewed image.
+	ArtBounds:
+		Part of the (deskewed) input image the grid was detected in, in coordinates of the input image.
+		Equal to bounds of the input image unless options.Crop is enabled and borders were found.
+		Axis results describe this part of the image.
+	Rows:
+		Detection output based on distances between pixels in each row, describes X axis of the image
+	Cols:
+		Detection output based on distances between pixels in each column, describes Y axis of the image
+	Strategy: uint8
+		One of STRATEGY_ constants, the pipeline variant that produced this result
+	Attempts: []uint8
+		All strategies that were run in order, including the ones whose results were rejected
+	Confidence:
+		Diagnostics of the run that produced this result, see Confidence
+*/
+type Result struct {
+	Image *image.RGBA
+	Rotation float64
+	ArtBounds image.Rectangle
+	Rows AxisResult
+	Cols AxisResult
+	Strategy uint8
+	Attempts []uint8
+	Confidence Confidence
+}
+
+/*
+	Holds all intermediate values of a single pipeline run, used by fallback strategies and debug output
+*/
+type pipelineState struct {
+	img_input *image.RGBA
+	img_preprocessed *image.RGBA
+
+	edge_distances_rows, edge_distances_cols *image.Gray
+	min_peak_height_rows, min_peak_height_cols uint8
+	edge_rows_binary, edge_cols_binary *image.Gray
+	edge_rows_binary_cleaned, edge_cols_binary_cleaned *image.Gray
+	cleanup_changed_rows, cleanup_changed_cols contrast.CleanupCounts
+
+	rows_edge_counts, cols_edge_counts []uint
+	most_frequent_rows, most_frequent_cols []int
+	rows_intervals, cols_intervals types.IntervalList
+
+	rows, cols AxisResult
+	restored *image.RGBA
+}
+
+/*
+	Restore detects pixel and gridline sizes of upscaled pixel art image
+	and returns restored image along with detection results of both axes.
+	If options.Fallback is enabled, variants of the pipeline are re-run on known edge cases, see restoreWithFallbacks.
+	If options.Crop is enabled, uniform borders are cropped before detection and borders without regular cells
+	found by the first detection are cropped before detecting the grid again, see ArtBounds of the result.
+
+	Returns ErrImageTooSmall if image is empty, ErrInvalidFilterParams if options are invalid (see ValidateOptions),
+	ErrTooFewEdges if not enough edges were detected to guess the grid on either axis,
+	or other errors listed in errors.go. Panics of the pipeline are recovered and returned as ErrInternal,
+	so a single unusual image can't crash the caller.
+	If debug output fails, the result is returned along with ErrDebugOutput.
+*/
+func Restore(input_img *image.RGBA, options Options) (result Result, err error) {
+	if input_img == nil || input_img.Rect.Empty() {
+		return Result{}, fmt.Errorf("restore: %w: input image is empty", ErrImageTooSmall)
+	}
+	if err := ValidateOptions(options); err != nil {
+		return Result{}, fmt.Errorf("restore: %w", err)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result, err = Result{}, fmt.Errorf("restore: %w: %v", ErrInternal, recovered)
+		}
+	}()
+
+	var rotation float64 = 0
+	if options.Deskew.Enabled {
+		input_img, rotation, err = deskewImage(input_img, options)
+		if err != nil {
+			return Result{}, fmt.Errorf("restore: %w", err)
+		}
+	}
+
+	if options.Crop.Enabled {
+		input_img = input_img.SubImage(cropUniformBorders(input_img, options.Crop)).(*image.RGBA)
+	}
+
+	result, state, err := restoreImage(input_img, options)
+	if err != nil {
+		return Result{}, fmt.Errorf("restore: %w", err)
+	}
+	result.ArtBounds = input_img.Rect
+
+	// borders without regular cells are only known after the grid was detected, so the art is restored again without them
+	if options.Crop.Enabled {
+		art_rect := cropNonPeriodicBorders(input_img, result.Rows, result.Cols, options.Crop)
+		if art_rect != input_img.Rect {
+			// margins between the removed border and the art are uniform again
+			art_rect = cropUniformBorders(input_img.SubImage(art_rect).(*image.RGBA), options.Crop)
+			cropped_result, cropped_state, cropped_err := restoreImage(input_img.SubImage(art_rect).(*image.RGBA), options)
+			if cropped_err == nil {
+				result, state = cropped_result, cropped_state
+				result.ArtBounds = art_rect
+			}
+		}
+	}
+	result.Rotation = rotation
+
+	if options.DebugDir != "" {
+		if debug_err := saveDebugOutput(options.DebugDir, state); debug_err != nil {
+			return result, fmt.Errorf("restore: %w: %w", ErrDebugOutput, debug_err)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+	ValidateOptions returns ErrInvalidFilterParams if options break a documented constraint of a pipeline stage,
+	Restore and RestoreRegions call it before running the pipeline. Parameters of disabled stages are not checked.
+	Unknown colour metrics are rejected too: the contrast package falls back to METRIC_RGB,
+	but a typo of a library caller shouldn't silently change the metric.
+*/
+func ValidateOptions(options Options) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidFilterParams, fmt.Sprintf(format, args...))
+	}
+
+	if !contrast.MetricIsKnown(options.ColorMetric) {
+		return invalid("unknown color metric %d", options.ColorMetric)
+	}
+	if options.PeakHeight.TileSize < 1 {
+		return invalid("threshold tile size must be at least 1, got %d", options.PeakHeight.TileSize)
+	}
+	cleanup := options.Cleanup
+	if cleanup.MinRunLength < 0 || cleanup.MaxGapLength < 0 || cleanup.MinClusterHeight < 0 {
+		return invalid("cleanup lengths must not be negative")
+	}
+	if options.Peaks.MinDistance < 1 {
+		return invalid("peak distance must be at least 1, got %d", options.Peaks.MinDistance)
+	}
+	if options.Joint.AspectRatio <= 0 {
+		return invalid("aspect ratio must be positive, got %v", options.Joint.AspectRatio)
+	}
+
+	hough := options.Deskew.Hough
+	if options.Deskew.Enabled && !(0 < hough.AngleStep && hough.AngleStep <= hough.MaxAngle) {
+		return invalid("deskew angle step must be in range (0, %v], got %v", hough.MaxAngle, hough.AngleStep)
+	}
+	crop := options.Crop
+	if options.Crop.Enabled {
+		if crop.EdgePeriods <= 0 || crop.MinRun < 1 || crop.MinCells < 1 || crop.MinSize < 1 {
+			return invalid("crop edge periods must be positive and min run, min cells and min size at least 1")
+		}
+		if crop.AlignedRatio < 0 || crop.AlignedRatio > 1 {
+			return invalid("crop aligned ratio must be in range [0, 1], got %v", crop.AlignedRatio)
+		}
+	}
+	style := options.CellStyle
+	if options.DetectCellStyle {
+		var valid bool = style.MinPeriod >= 3 && 0 <= style.RefineRange && style.RefineRange < 0.5 &&
+			0 < style.MinPeakFraction && style.MinPeakFraction <= 1 && style.MinShadingEdges >= 3 &&
+			0 < style.MinInteriorFraction && style.MinInteriorFraction < 1 &&
+			0 <= style.MaxInteriorEdgeShare && style.MaxInteriorEdgeShare < 1 && style.MinBandWidth >= 0 &&
+			0 <= style.MaxValleyFraction && style.MaxValleyFraction < 1 && 0 <= style.MinSideRatio && style.MinSideRatio <= 1
+		if !valid {
+			return invalid("cell style parameters break constraints of gridlines.CellStyleParams")
+		}
+	}
+
+	regions := options.Regions
+	if regions.BlockSize < 8 || regions.MinBlocks < 1 {
+		return invalid("region block size must be at least 8 and min blocks at least 1, got %d and %d",
+			regions.BlockSize, regions.MinBlocks,
+		)
+	}
+	if regions.PeriodTolerance < 0 || regions.PeriodTolerance >= 1 {
+		return invalid("region period tolerance must be in range [0, 1), got %v", regions.PeriodTolerance)
+	}
+	return nil
+}
+
+/*
+	Runs the pipeline on the image, with fallback strategies if they are enabled
+*/
+func restoreImage(input_img *image.RGBA, options Options) (Result, pipelineState, error) {
+	if options.Fallback.Enabled {
+		return restoreWithFallbacks(input_img, options)
+	}
+	state, err := runPipeline(input_img, options)
+	return resultFromState(state, STRATEGY_BASE), state, err
+}
+
+/*
+	Runs the whole pipeline once: preprocessing, edge detection, gridline guessing, error fixing and sampling.
+*/
+func runPipeline(input_img *image.RGBA, options Options) (pipelineState, error) {
+	img_width, img_height := input_img.Rect.Dx(), input_img.Rect.Dy()
+	var state pipelineState
+
+	err := detectEdgeMaps(&state, input_img, options)
+	if err != nil {
+		return state, err
+	}
+
+	state.rows_edge_counts = contrast.EdgeCountsWithMode(state.edge_rows_binary_cleaned, options.MostFrequent.CountMode)
+	state.cols_edge_counts = contrast.EdgeCountsWithMode(state.edge_cols_binary_cleaned, options.MostFrequent.CountMode)
+
+	state.most_frequent_rows = contrast.SelectMostFrequent(state.rows_edge_counts, options.MostFrequent)
+	state.most_frequent_cols = contrast.SelectMostFrequent(state.cols_edge_counts, options.MostFrequent)
+
+	var rows_style, cols_style gridlines.CellStyle
+	if options.DetectCellStyle {
+		rows_style = detectCellStyle(state.rows_edge_counts, state.most_frequent_rows, options)
+		cols_style = detectCellStyle(state.cols_edge_counts, state.most_frequent_cols, options)
+		state.most_frequent_rows = gridlines.FilterShadingEdges(state.most_frequent_rows, rows_style)
+		state.most_frequent_cols = gridlines.FilterShadingEdges(state.most_frequent_cols, cols_style)
+	}
+
+	state.rows_intervals = types.IntervalListFromSortedEdgeIndexes(state.most_frequent_rows, img_width)
+	state.cols_intervals = types.IntervalListFromSortedEdgeIndexes(state.most_frequent_cols, img_height)
+
+	state.rows, err = guessAxis(state.rows_edge_counts, state.rows_intervals, options)
+	if err != nil {
+		return state, err
+	}
+	state.cols, err = guessAxis(state.cols_edge_counts, state.cols_intervals, options)
+	if err != nil {
+		return state, err
+	}
+	state.rows.CellStyle, state.cols.CellStyle = rows_style, cols_style
+
+	applyJointGuess(&state.rows, &state.cols, state.rows_intervals, state.cols_intervals, options)
+
+	if err = finishAxis(&state.rows, state.most_frequent_rows, state.rows_intervals, options); err != nil {
+		return state, err
+	}
+	if err = finishAxis(&state.cols, state.most_frequent_cols, state.cols_intervals, options); err != nil {
+		return state, err
+	}
+	detectMajorGridByColor(&state.rows, state.img_input, false, options)
+	detectMajorGridByColor(&state.cols, state.img_input, true, options)
+
+	err = sampleRestoredImage(&state, options)
+	return state, err
+}
+
+/*
+	Runs preprocessing and edge detection stages of the pipeline, filling the state up to cleaned binary edge maps.
+*/
+func detectEdgeMaps(state *pipelineState, input_img *image.RGBA, options Options) error {
+	var err error
+	state.img_input = input_img
+	state.img_preprocessed = input_img
+	if options.KuwaharaRadius >= 1 {
+		state.img_preprocessed, err = kuwahara.KuwaharaGaussian(input_img, options.KuwaharaRadius, options.KuwaharaSigma)
+		if err != nil {
+			return err
+		}
+	}
+
+	state.edge_distances_rows = contrast.CalculatePixelEdgeDistancesWithMetric(state.img_preprocessed, false, options.ColorMetric)
+	state.edge_distances_cols = contrast.CalculatePixelEdgeDistancesWithMetric(state.img_preprocessed, true, options.ColorMetric)
+
+	state.min_peak_height_rows = contrast.CalculateMinPeakHeight(state.edge_distances_cols.Pix, options.PeakHeight)
+	state.min_peak_height_cols = contrast.CalculateMinPeakHeight(state.edge_distances_rows.Pix, options.PeakHeight)
+
+	state.edge_rows_binary = contrast.ThresholdAdaptive(state.edge_distances_rows, state.min_peak_height_rows, options.PeakHeight)
+	state.edge_cols_binary = contrast.ThresholdAdaptive(state.edge_distances_cols, state.min_peak_height_cols, options.PeakHeight)
+
+	state.edge_rows_binary_cleaned, state.cleanup_changed_rows = contrast.CleanupEdgeArtifacts(state.edge_rows_binary, options.Cleanup)
+	state.edge_cols_binary_cleaned, state.cleanup_changed_cols = contrast.CleanupEdgeArtifacts(state.edge_cols_binary, options.Cleanup)
+
+	// peaks are searched after cleanup, which would otherwise remove peaks jittering between rows of a smeared edge
+	if options.Peaks.Enabled {
+		state.edge_rows_binary_cleaned = contrast.SuppressNonMaxima(
+			state.img_preprocessed, state.edge_distances_rows, state.edge_rows_binary_cleaned, false, options.ColorMetric, options.Peaks,
+		)
+		state.edge_cols_binary_cleaned = contrast.SuppressNonMaxima(
+			state.img_preprocessed, state.edge_distances_cols, state.edge_cols_binary_cleaned, true, options.ColorMetric, options.Peaks,
+		)
+	}
+	return nil
+}
+
+/*
+	Samples restored image from input image using fixed combined lists or lattices of both axes stored in the state
+*/
+func sampleRestoredImage(state *pipelineState, options Options) error {
+	// shading bands of shaded cells are trimmed, so the cell colour is sampled instead of its highlight
+	params := options.Sampling
+	for _, style := range [2]gridlines.CellStyle{state.rows.CellStyle, state.cols.CellStyle} {
+		params.InteriorMargin = max(params.InteriorMargin, float32(gridlines.CellStyleMargin(style)))
+	}
+
+	var err error
+	if options.SampleLattice {
+		state.restored, err = sampling.SampleRestoredImageLattice(
+			state.img_input,
+			[2]types.Lattice{state.cols.Lattice, state.rows.Lattice},
+			params,
+		)
+		return err
+	}
+	state.restored, err = sampling.SampleRestoredImage(
+		state.img_input,
+		[2]types.CombinedList{state.cols.Fixed, state.rows.Fixed},
+		params,
+	)
+	return err
+}
+
+func resultFromState(state pipelineState, strategy uint8) Result {
+	return Result{
+		Image: state.restored,
+		Rows: state.rows,
+		Cols: state.cols,
+		Strategy: strategy,
+		Attempts: []uint8{strategy},
+		Confidence: confidenceFromState(state),
+	}
+}
+
+/*
+	Runs gridline guessing, error fixing and lattice fitting stages on edges and interval list of a single axis,
+	without coupling it to the other axis.
+*/
+func detectAxis(edges []int, edge_counts []uint, intervals types.IntervalList, options Options) (AxisResult, error) {
+	axis, err := guessAxis(edge_counts, intervals, options)
+	if err != nil {
+		return axis, err
+	}
+	err = finishAxis(&axis, edges, intervals, options)
+	return axis, err
+}
+
+/*
+	Runs gridline guessing stage on interval list of a single axis.
+	Edge counts are used only by the spectral estimator.
+*/
+func guessAxis(edge_counts []uint, intervals types.IntervalList, options Options) (AxisResult, error) {
+	var axis AxisResult
+	if len(intervals.Intervals) < 3 {
+		return axis, fmt.Errorf("%w: %d intervals", common.ErrTooFewEdges, len(intervals.Intervals))
+	}
+
+	axis.PixelGuess, axis.GridGuess, axis.Scores = gridlines.GuessGridlineParametersWithScores(intervals)
+	applySpectralEstimate(&axis, edge_counts, intervals, options.Estimator)
+	return axis, nil
+}
+
+/*
+	Couples pixel and gridline guesses of both axes by gridlines.GuessJoint.
+	Scores of a replaced guess come from gridlines.GuessJointWithScores, JointCorrected is only ever set, never cleared,
+	so axes corrected by an earlier run stay marked. Returns which axes had their guesses replaced.
+*/
+func applyJointGuess(rows, cols *AxisResult, rows_intervals, cols_intervals types.IntervalList, options Options) [2]bool {
+	rows_guess, cols_guess, corrected, scores := gridlines.GuessJointWithScores(
+		rows_intervals, cols_intervals,
+		[2]types.IntervalRangeEntry{rows.PixelGuess, rows.GridGuess},
+		[2]types.IntervalRangeEntry{cols.PixelGuess, cols.GridGuess},
+		options.Joint,
+	)
+	if corrected[0] {
+		rows.PixelGuess, rows.GridGuess, rows.JointCorrected, rows.Scores = rows_guess[0], rows_guess[1], true, scores[0]
+	}
+	if corrected[1] {
+		cols.PixelGuess, cols.GridGuess, cols.JointCorrected, cols.Scores = cols_guess[0], cols_guess[1], true, scores[1]
+	}
+	return corrected
+}
+
+/*
+	Runs error fixing and lattice fitting stages on an axis whose pixel and gridline sizes were already guessed.
+*/
+func finishAxis(axis *AxisResult, edges []int, intervals types.IntervalList, options Options) error {
+	var err error
+	axis.Combined, err = types.CombinedFromIntervalList(
+		intervals, [2]types.IntervalRangeEntry{axis.PixelGuess, axis.GridGuess},
+	)
+	if err != nil {
+		return err
+	}
+	if options.DetectMajorGrid {
+		axis.Major = gridlines.DetectMajorGrid(axis.Combined, axis.PixelGuess, axis.GridGuess, options.MajorGrid)
+		axis.Combined = gridlines.MarkMajorGridlines(axis.Combined, axis.PixelGuess, axis.GridGuess, axis.Major)
+	}
+	axis.Fixed, err = gridlines.GridlinesFixErrors(axis.Combined, axis.PixelGuess, axis.GridGuess)
+	if err != nil {
+		return err
+	}
+	axis.Lattice = gridlines.FitLattice(edges, axis.PixelGuess, axis.GridGuess, options.Lattice)
+	return nil
+}
+
+/*
+	Looks for major gridlines that differ from the regular ones by colour (see gridlines.DetectMajorGridByColor)
+	on a finished axis of img, vertical selects the Y axis. Axes that already have a major grid of wider lines are kept.
+	Gridline pixels are compared with pixels half a lattice period away, inside of neighbouring cells.
+*/
+func detectMajorGridByColor(axis *AxisResult, img *image.RGBA, vertical bool, options Options) {
+	if !options.DetectMajorGrid || axis.Major.Period > 0 {
+		return
+	}
+	colors, strengths := contrast.GridlineColors(
+		img, vertical, int(axis.Lattice.Period / 2.0), options.MajorGrid.ColorTolerance,
+	)
+	axis.Major = gridlines.DetectMajorGridByColor(axis.Lattice, colors, strengths, options.MajorGrid)
+}
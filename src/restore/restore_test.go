@@ -0,0 +1,33 @@
+package restore
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+/*
+	Invalid options are reported with ErrInvalidFilterParams before the pipeline runs,
+	a zero block size used to reach a division by zero inside RestoreRegions.
+*/
+func TestInvalidOptionsAreRejected(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 128, 128))
+
+	options := GetBaseOptions()
+	options.Regions.BlockSize = 0
+	if _, err := RestoreRegions(img, options); !errors.Is(err, ErrInvalidFilterParams) {
+		t.Errorf("RestoreRegions with block size 0: expected ErrInvalidFilterParams, got %v", err)
+	}
+
+	options = GetBaseOptions()
+	options.ColorMetric = 200
+	if _, err := Restore(img, options); !errors.Is(err, ErrInvalidFilterParams) {
+		t.Errorf("Restore with unknown color metric: expected ErrInvalidFilterParams, got %v", err)
+	}
+
+	options = GetBaseOptions()
+	options.Peaks.MinDistance = 0
+	if _, err := Restore(img, options); !errors.Is(err, ErrInvalidFilterParams) {
+		t.Errorf("Restore with peak distance 0: expected ErrInvalidFilterParams, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package sampling
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/types"
+)
+
+/*
+	SampleRestoredImageLattice works like SampleRestoredImage, but cells are placed by continuous lattices
+	instead of combined lists, so art with non-integer pixel size is sampled around true cell centres.
+
+	Lattices must be provided in the same order as combined lists of SampleRestoredImage:
+		lattices[0] describes Y axis (rows of cells), lattices[1] describes X axis (columns of cells)
+
+	Cells cut off by the image border are included only if their visible part is at least
+	EdgeCellMinFraction * <cell length> long. Returns ErrInvalidLattice if a lattice has period lower than 1
+	or gridline at least as wide as the period.
+*/
+func SampleRestoredImageLattice(img *image.RGBA, lattices [2]types.Lattice, params SamplingParams) (*image.RGBA, error) {
+	for _, lattice := range lattices {
+		if lattice.Period < 1.0 || lattice.GridWidth >= lattice.Period {
+			return nil, fmt.Errorf("%w: lattice period %.3f with grid width %.3f",
+				common.ErrInvalidLattice, lattice.Period, lattice.GridWidth)
+		}
+	}
+
+	cell_ranges_y := GetLatticeCellRanges(lattices[0], img.Rect.Dy(), params.EdgeCellMinFraction)
+	cell_ranges_x := GetLatticeCellRanges(lattices[1], img.Rect.Dx(), params.EdgeCellMinFraction)
+
+	height, width := len(cell_ranges_y), len(cell_ranges_x)
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y, range_y := range cell_ranges_y {
+		interior_y := latticeCellInterior(range_y, lattices[0], img.Rect.Dy(), params.InteriorMargin)
+		for x, range_x := range cell_ranges_x {
+			interior_x := latticeCellInterior(range_x, lattices[1], img.Rect.Dx(), params.InteriorMargin)
+			cell_rect := image.Rect(interior_x[0], interior_y[0], interior_x[1], interior_y[1])
+
+			var color [4]uint8 = sampleCellColor(img, cell_rect, params.Mode)
+			flat_id := result.PixOffset(x, y)
+			copy(result.Pix[flat_id: flat_id + 4], color[:])
+		}
+	}
+
+	return result, nil
+}
+
+/*
+	Returns [start, end) ranges of all art pixels of the lattice that are visible on an axis of given length,
+	clipped to the axis. Cells whose visible part is shorter than min_edge_fraction * <cell length> are dropped.
+*/
+func GetLatticeCellRanges(lattice types.Lattice, dim_length int, min_edge_fraction float32) [][2]float64 {
+	cell_length := lattice.Period - lattice.GridWidth
+	min_visible := max(cell_length * float64(min_edge_fraction), math.SmallestNonzeroFloat64)
+
+	ranges := make([][2]float64, 0, int(float64(dim_length) / lattice.Period) + 2)
+	for k := types.LatticeFirstCell(lattice); ; k++ {
+		cell := types.LatticeCellRange(lattice, k)
+		if cell[0] >= float64(dim_length) {
+			break
+		}
+		visible := [2]float64{max(cell[0], 0.0), min(cell[1], float64(dim_length))}
+		if visible[1] - visible[0] >= min_visible {
+			ranges = append(ranges, visible)
+		}
+	}
+	return ranges
+}
+
+/*
+	Shrinks visible cell range by margin * <cell length> from both sides and rounds it to whole pixels.
+	Resulting range always contains at least one pixel, the one under the centre of the visible range.
+*/
+func latticeCellInterior(cell_range [2]float64, lattice types.Lattice, dim_length int, margin float32) [2]int {
+	trim := (lattice.Period - lattice.GridWidth) * float64(margin)
+	start := int(math.Ceil(cell_range[0] + trim))
+	end := int(math.Floor(cell_range[1] - trim))
+	if end > start {
+		return [2]int{start, end}
+	}
+
+	centre := int((cell_range[0] + cell_range[1]) / 2.0)
+	centre = min(max(centre, 0), dim_length - 1)
+	return [2]int{centre, centre + 1}
+}
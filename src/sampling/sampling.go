@@ -0,0 +1,261 @@
+package sampling
+
+import (
+	"fmt"
+	"image"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/types"
+)
+
+/*
+	Defines a set of uint8 constants used to select how the colour of a single art pixel cell is sampled
+	SAMPLE_MEDIAN:
+		each channel is set to median value of that channel among all pixels of cell interior
+	SAMPLE_MODE:
+		the most common colour among all pixels of cell interior is chosen
+*/
+const (
+	SAMPLE_MEDIAN uint8 = iota
+	SAMPLE_MODE uint8 = iota
+)
+
+var sample_mode_names = [2]string{"median", "mode"}
+
+/*
+	Returns short name of a SAMPLE_ constant, used in reports and command line flags
+*/
+func SamplingModeName(mode uint8) string {
+	if int(mode) >= len(sample_mode_names) {
+		return "unknown"
+	}
+	return sample_mode_names[mode]
+}
+
+/*
+	Returns SAMPLE_ constant with given name and true, or false if no sampling mode has that name
+*/
+func SamplingModeFromName(name string) (uint8, bool) {
+	for mode, mode_name := range sample_mode_names {
+		if mode_name == name {
+			return uint8(mode), true
+		}
+	}
+	return 0, false
+}
+
+/*
+	Mode: uint8
+		One of the SAMPLE_ constants, decides how the cell colour is computed
+	InteriorMargin: float32
+		Fraction of cell length trimmed from each side of the cell before sampling.
+		Used to avoid anti-aliased or blurred borders of the cell. At least 1 pixel of the cell is always sampled.
+	EdgeCellMinFraction: float32
+		Pixel cells on the image edges can be cut off by the image border.
+		Edge cells shorter than EdgeCellMinFraction * <average pixel cell length> are not included in the result.
+
+	Constraints:
+		0 <= InteriorMargin < 0.5
+		0 <= EdgeCellMinFraction <= 1.0
+*/
+type SamplingParams struct {
+	Mode uint8
+	InteriorMargin float32
+	EdgeCellMinFraction float32
+}
+
+func GetBaseSamplingParams() SamplingParams {
+	return SamplingParams{
+		Mode: SAMPLE_MEDIAN,
+		InteriorMargin: 0.15,
+		EdgeCellMinFraction: 0.5,
+	}
+}
+
+/*
+	SampleRestoredImage creates a new image where each art pixel of input image is represented by exactly one pixel.
+
+	Combined lists must be provided in the same order as in visualizations package:
+		combined_lists[0] describes Y axis (rows of cells), combined_lists[1] describes X axis (columns of cells)
+	Combined lists should be "fixed" (contain no unknown items), unknown items are skipped just like grid items.
+
+	Only INTERVAL_PIXEL items are sampled, INTERVAL_GRID bands are ignored.
+	Resulting image is normalized (rectangle starts at 0,0) and has alpha channel sampled the same way as colour channels.
+	Returns ErrAxisMismatch if total length of a combined list differs from the image dimension it describes.
+*/
+func SampleRestoredImage(img *image.RGBA, combined_lists [2]types.CombinedList, params SamplingParams) (*image.RGBA, error) {
+	dimensions := [2]int{img.Rect.Dy(), img.Rect.Dx()}
+	for axis, combined_list := range combined_lists {
+		var total_length int = 0
+		for _, length := range combined_list.Intervals {
+			total_length += int(length)
+		}
+		if total_length != dimensions[axis] {
+			return nil, fmt.Errorf("%w: combined list of length %d describes image dimension %d",
+				common.ErrAxisMismatch, total_length, dimensions[axis])
+		}
+	}
+
+	cell_ranges_y := GetPixelCellRanges(combined_lists[0], params.EdgeCellMinFraction)
+	cell_ranges_x := GetPixelCellRanges(combined_lists[1], params.EdgeCellMinFraction)
+
+	height, width := len(cell_ranges_y), len(cell_ranges_x)
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y, range_y := range cell_ranges_y {
+		interior_y := cellInterior(range_y, params.InteriorMargin)
+		for x, range_x := range cell_ranges_x {
+			interior_x := cellInterior(range_x, params.InteriorMargin)
+			cell_rect := image.Rect(interior_x[0], interior_y[0], interior_x[1], interior_y[1])
+
+			var color [4]uint8 = sampleCellColor(img, cell_rect, params.Mode)
+			flat_id := result.PixOffset(x, y)
+			copy(result.Pix[flat_id: flat_id + 4], color[:])
+		}
+	}
+
+	return result, nil
+}
+
+/*
+	Given a combined list, returns slice of [start, end) pixel ranges of all non-zero INTERVAL_PIXEL items.
+	These are exactly the cells that SampleRestoredImage turns into pixels of the restored image.
+	First and last item of the list are dropped if they are pixel items shorter than
+	min_edge_fraction * <average length of non-edge pixel items>
+*/
+func GetPixelCellRanges(combined_list types.CombinedList, min_edge_fraction float32) [][2]int {
+	ranges := make([][2]int, 0, len(combined_list.Intervals) / 2 + 1)
+
+	var sum_pixel, count_pixel int = 0, 0
+	var position int = 0
+	// edge items are dropped below only if they were appended, zero-length ones are skipped here
+	var first_appended, last_appended bool = false, false
+	last_id := len(combined_list.Intervals) - 1
+	for i, length := range combined_list.Intervals {
+		start, end := position, position + int(length)
+		position = end
+
+		if combined_list.IntervalTypes[i] != types.INTERVAL_PIXEL || length == 0 {
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+
+		var is_edge bool = i == 0 || i == last_id
+		if !is_edge {
+			sum_pixel += int(length)
+			count_pixel += 1
+		}
+		first_appended = first_appended || i == 0
+		last_appended = last_appended || i == last_id
+	}
+
+	if len(ranges) == 0 || count_pixel == 0 {
+		return ranges
+	}
+
+	min_edge_length := float32(sum_pixel) / float32(count_pixel) * min_edge_fraction
+
+	// dropping cut off cells from the right first, so that indexes of left cells stay the same
+	if last_appended && float32(combined_list.Intervals[last_id]) < min_edge_length {
+		ranges = ranges[:len(ranges) - 1]
+	}
+	if len(ranges) > 0 && first_appended && float32(combined_list.Intervals[0]) < min_edge_length {
+		ranges = ranges[1:]
+	}
+
+	return ranges
+}
+
+/*
+	Shrinks [start, end) range by margin * <range length> from both sides.
+	Resulting range always contains at least one pixel.
+*/
+func cellInterior(cell_range [2]int, margin float32) [2]int {
+	length := cell_range[1] - cell_range[0]
+	trim := int(float32(length) * margin)
+	if length - 2 * trim < 1 {
+		trim = (length - 1) / 2
+	}
+	return [2]int{cell_range[0] + trim, cell_range[1] - trim}
+}
+
+/*
+	Computes representative color of the rectangle of img, rectangle is relative to img.Rect.Min
+*/
+func sampleCellColor(img *image.RGBA, cell_rect image.Rectangle, mode uint8) [4]uint8 {
+	if mode == SAMPLE_MODE {
+		return modeColorOfRect(img, cell_rect)
+	}
+	return medianColorOfRect(img, cell_rect)
+}
+
+/*
+	Returns per-channel median color of the rectangle.
+	Fully transparent pixels are left out of the median, so anti-aliased sprite borders don't darken the cell,
+	and the cell is fully transparent if they make up more than half of it.
+	Colour channels are clamped to alpha to keep the result valid alpha-premultiplied data.
+*/
+func medianColorOfRect(img *image.RGBA, cell_rect image.Rectangle) [4]uint8 {
+	pixel_count := cell_rect.Dx() * cell_rect.Dy()
+	buffer := make([]uint8, pixel_count * 4)
+	channels := [4][]uint8{
+		buffer[0 * pixel_count: 1 * pixel_count],
+		buffer[1 * pixel_count: 2 * pixel_count],
+		buffer[2 * pixel_count: 3 * pixel_count],
+		buffer[3 * pixel_count: 4 * pixel_count],
+	}
+
+	id := 0
+	for y := cell_rect.Min.Y; y < cell_rect.Max.Y; y++ {
+		for x := cell_rect.Min.X; x < cell_rect.Max.X; x++ {
+			flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+			if img.Pix[flat_id + 3] == 0 {
+				continue
+			}
+			for channel := 0; channel < 4; channel++ {
+				channels[channel][id] = img.Pix[flat_id + channel]
+			}
+			id += 1
+		}
+	}
+
+	var result [4]uint8
+	if id * 2 < pixel_count || id == 0 {
+		return result
+	}
+	for channel := 0; channel < 4; channel++ {
+		result[channel] = common.MedianOfSliceU8(channels[channel][:id])
+	}
+	for channel := 0; channel < 3; channel++ {
+		result[channel] = min(result[channel], result[3])
+	}
+	return result
+}
+
+/*
+	Returns the most common color in the rectangle.
+	If multiple colors are equally common, the one that reached the top count first (row-major order) is returned,
+	so pixels A B B A give B.
+*/
+func modeColorOfRect(img *image.RGBA, cell_rect image.Rectangle) [4]uint8 {
+	counts := make(map[[4]uint8]int)
+
+	var best_color [4]uint8
+	var best_count int = 0
+	for y := cell_rect.Min.Y; y < cell_rect.Max.Y; y++ {
+		for x := cell_rect.Min.X; x < cell_rect.Max.X; x++ {
+			flat_id := img.PixOffset(x + img.Rect.Min.X, y + img.Rect.Min.Y)
+			var color [4]uint8
+			copy(color[:], img.Pix[flat_id: flat_id + 4])
+
+			counts[color] += 1
+			if counts[color] > best_count {
+				best_count = counts[color]
+				best_color = color
+			}
+		}
+	}
+	return best_color
+}
@@ -0,0 +1,40 @@
+package sampling
+
+import (
+	"slices"
+	"testing"
+)
+
+import (
+	"pixel_restoration/types"
+)
+
+/*
+	Short edge cells are dropped, zero-length edge items are never sampled, so the cell next to them stays.
+*/
+func TestGetPixelCellRangesEdges(t *testing.T) {
+	cases := []struct {
+		name string
+		intervals []uint
+		expected [][2]int
+	}{
+		{"full edges", []uint{6, 1, 6, 1, 6}, [][2]int{{0, 6}, {7, 13}, {14, 20}}},
+		{"short edges", []uint{2, 1, 6, 1, 6, 1, 2}, [][2]int{{3, 9}, {10, 16}}},
+		{"zero-length right edge", []uint{6, 1, 6, 1, 6, 1, 0}, [][2]int{{0, 6}, {7, 13}, {14, 20}}},
+		{"zero-length left edge", []uint{0, 1, 6, 1, 6, 1, 6}, [][2]int{{1, 7}, {8, 14}, {15, 21}}},
+	}
+
+	for _, test_case := range cases {
+		combined := types.CombinedList{
+			Intervals: test_case.intervals,
+			IntervalTypes: make([]uint8, len(test_case.intervals)),
+		}
+		for i := range combined.IntervalTypes {
+			combined.IntervalTypes[i] = [2]uint8{types.INTERVAL_PIXEL, types.INTERVAL_GRID}[i % 2]
+		}
+		ranges := GetPixelCellRanges(combined, 0.5)
+		if !slices.Equal(ranges, test_case.expected) {
+			t.Errorf("%s: expected %v, got %v", test_case.name, test_case.expected, ranges)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package tiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/images"
+)
+
+/*
+	Single tile cut out of the restored image.
+
+	Column, Row:
+		Position of the tile in the tile grid
+	Rect:
+		Placement of the tile in the restored image
+	Image:
+		Copy of the tile pixels, normalized to start at 0,0
+*/
+type Tile struct {
+	Column int
+	Row int
+	Rect image.Rectangle
+	Image *image.RGBA
+}
+
+/*
+	JSON description of written tiles, all positions and sizes are in pixels of the restored image.
+*/
+type Atlas struct {
+	Source string `json:"source"`
+	ImageWidth int `json:"image_width"`
+	ImageHeight int `json:"image_height"`
+	TileWidth int `json:"tile_width"`
+	TileHeight int `json:"tile_height"`
+	OffsetX int `json:"offset_x"`
+	OffsetY int `json:"offset_y"`
+	SpacingX int `json:"spacing_x"`
+	SpacingY int `json:"spacing_y"`
+	Columns int `json:"columns"`
+	Rows int `json:"rows"`
+	Tiles []AtlasEntry `json:"tiles"`
+}
+
+type AtlasEntry struct {
+	File string `json:"file"`
+	Column int `json:"column"`
+	Row int `json:"row"`
+	X int `json:"x"`
+	Y int `json:"y"`
+	Width int `json:"width"`
+	Height int `json:"height"`
+}
+
+/*
+	Cuts all complete tiles of the grid out of the image in row-major order.
+	If params.SkipEmpty is set and grid has a background colour, tiles filled only with background are skipped.
+*/
+func SplitTiles(img *image.RGBA, grid TileGrid, params TileParams) []Tile {
+	tiles := make([]Tile, 0, grid.X.Count * grid.Y.Count)
+	for row := 0; row < grid.Y.Count; row++ {
+		range_y := TileRange(grid.Y, row)
+		for column := 0; column < grid.X.Count; column++ {
+			range_x := TileRange(grid.X, column)
+			rect := image.Rect(range_x[0], range_y[0], range_x[1], range_y[1])
+			if params.SkipEmpty && grid.HasBackground && isBackgroundOnly(img, rect, grid.Background, params.ColorTolerance) {
+				continue
+			}
+
+			tile_img := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+			for y := 0; y < rect.Dy(); y++ {
+				source_id := img.PixOffset(img.Rect.Min.X + rect.Min.X, img.Rect.Min.Y + rect.Min.Y + y)
+				copy(tile_img.Pix[tile_img.PixOffset(0, y):tile_img.PixOffset(0, y) + rect.Dx() * 4], img.Pix[source_id:source_id + rect.Dx() * 4])
+			}
+			tiles = append(tiles, Tile{Column: column, Row: row, Rect: rect, Image: tile_img})
+		}
+	}
+	return tiles
+}
+
+/*
+	Creates the directory if missing and writes every tile as tile_<row>_<column>.png
+	along with atlas.json describing grid and tile positions. Source is stored in the atlas as is.
+	Returns the written atlas, errors of individual files are joined.
+*/
+func WriteAtlas(dir string, source string, img *image.RGBA, grid TileGrid, tiles []Tile) (Atlas, error) {
+	atlas := Atlas{
+		Source: source,
+		ImageWidth: img.Rect.Dx(),
+		ImageHeight: img.Rect.Dy(),
+		TileWidth: grid.X.Size,
+		TileHeight: grid.Y.Size,
+		OffsetX: grid.X.Offset,
+		OffsetY: grid.Y.Offset,
+		SpacingX: grid.X.Spacing,
+		SpacingY: grid.Y.Spacing,
+		Columns: grid.X.Count,
+		Rows: grid.Y.Count,
+		Tiles: make([]AtlasEntry, 0, len(tiles)),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return atlas, err
+	}
+
+	var errs []error
+	for _, tile := range tiles {
+		entry := AtlasEntry{
+			File: fmt.Sprintf("tile_%03d_%03d.png", tile.Row, tile.Column),
+			Column: tile.Column,
+			Row: tile.Row,
+			X: tile.Rect.Min.X,
+			Y: tile.Rect.Min.Y,
+			Width: tile.Rect.Dx(),
+			Height: tile.Rect.Dy(),
+		}
+		if err := images.RGBASaveToFile(filepath.Join(dir, entry.File), tile.Image); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		atlas.Tiles = append(atlas.Tiles, entry)
+	}
+
+	atlas_file, err := os.Create(filepath.Join(dir, "atlas.json"))
+	if err != nil {
+		return atlas, errors.Join(append(errs, err)...)
+	}
+	encoder := json.NewEncoder(atlas_file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(atlas); err != nil {
+		errs = append(errs, err)
+	}
+	// a failed close can leave atlas.json truncated
+	if err := atlas_file.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return atlas, errors.Join(errs...)
+}
+
+/*
+	Returns true if all pixels of the rectangle (relative to img.Rect.Min) are within tolerance of background colour
+*/
+func isBackgroundOnly(img *image.RGBA, rect image.Rectangle, background [4]uint8, tolerance uint8) bool {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if !common.ColorsClose(common.PixelColor(img, x, y), background, tolerance) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,359 @@
+package tiles
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+import (
+	"pixel_restoration/common"
+	"pixel_restoration/gridlines"
+	"pixel_restoration/sampling"
+	"pixel_restoration/types"
+)
+
+/*
+	ErrNoTiles:
+		Neither axis of the restored image could be split into at least 2 regularly spaced tiles
+*/
+var ErrNoTiles = errors.New("no tile structure found")
+
+/*
+	ColorTolerance: uint8
+		Maximum difference of any channel for two colours to be considered the same,
+		used when looking for uniform separator lines
+	MinRegularity: float64
+		Minimum fraction of expected separators between tiles that must be present for the tile period to be accepted
+	SkipEmpty: bool
+		If true, tiles filled only with background colour are not returned by SplitTiles
+
+	Constraints:
+		0 < MinRegularity <= 1
+*/
+type TileParams struct {
+	ColorTolerance uint8
+	MinRegularity float64
+	SkipEmpty bool
+}
+
+func GetBaseTileParams() TileParams {
+	return TileParams{
+		ColorTolerance: 8,
+		MinRegularity: 0.75,
+		SkipEmpty: true,
+	}
+}
+
+/*
+	Describes placement of tiles along one axis of the restored image, all values are in art pixels.
+	Tile k spans [Offset + k * (Size + Spacing), Offset + k * (Size + Spacing) + Size)
+
+	Offset:
+		Position of the first tile (margin before it)
+	Size:
+		Length of a tile
+	Spacing:
+		Length of separator between two tiles, 0 if tiles were separated by gridlines removed during sampling
+	Count:
+		Number of complete tiles
+*/
+type TileAxis struct {
+	Offset int
+	Size int
+	Spacing int
+	Count int
+}
+
+/*
+	Tile layout of the whole restored image.
+
+	X, Y:
+		Tile placement along width and height of the image
+	Background: [4]uint8
+		RGBA colour of separator lines, meaningful only if HasBackground is true
+	HasBackground: bool
+		True if tiles of at least one axis were found from uniform separator lines
+*/
+type TileGrid struct {
+	X TileAxis
+	Y TileAxis
+	Background [4]uint8
+	HasBackground bool
+}
+
+/*
+	Detects tile layout of a restored image (one pixel per art pixel).
+
+	On each axis, tiles are first looked for between uniform lines (rows or columns of a single colour)
+	of the image background colour, which is the most common colour of uniform lines. Separators must repeat
+	with a regular period, see TileParams.MinRegularity.
+	If no separators are found on an axis, the hint of that axis is used, see TileAxisFromMajorGrid.
+	Hints are given in [X, Y] order, zero TileAxis means no hint.
+	An axis without tiles is treated as a single tile spanning the whole image.
+
+	Returns ErrNoTiles if neither axis splits into at least 2 tiles.
+*/
+func DetectTiles(img *image.RGBA, hints [2]TileAxis, params TileParams) (TileGrid, error) {
+	var grid TileGrid
+	if img == nil || img.Rect.Empty() {
+		return grid, fmt.Errorf("%w: image is empty", ErrNoTiles)
+	}
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+
+	columns_colors, columns_uniform := uniformLines(img, true, params.ColorTolerance)
+	rows_colors, rows_uniform := uniformLines(img, false, params.ColorTolerance)
+	background, found := mostCommonColor(columns_colors, columns_uniform, rows_colors, rows_uniform)
+
+	axes := [2]*TileAxis{&grid.X, &grid.Y}
+	lengths := [2]int{width, height}
+	if found {
+		separators := [2][]bool{
+			separatorLines(columns_colors, columns_uniform, background, params.ColorTolerance),
+			separatorLines(rows_colors, rows_uniform, background, params.ColorTolerance),
+		}
+		for axis := range axes {
+			*axes[axis] = separatorTileAxis(separators[axis], params.MinRegularity)
+			if axes[axis].Count >= 2 {
+				grid.Background, grid.HasBackground = background, true
+			}
+		}
+	}
+
+	for axis := range axes {
+		hint := hints[axis]
+		var hint_fits bool = hint.Size > 0 && hint.Count >= 2 &&
+			hint.Offset + hint.Count * (hint.Size + hint.Spacing) - hint.Spacing <= lengths[axis]
+		if axes[axis].Count < 2 && hint_fits {
+			*axes[axis] = hint
+		}
+	}
+
+	if grid.X.Count < 2 && grid.Y.Count < 2 {
+		return grid, fmt.Errorf("%w: %dx%d image", ErrNoTiles, width, height)
+	}
+	for axis := range axes {
+		if axes[axis].Count < 2 {
+			*axes[axis] = TileAxis{Offset: 0, Size: lengths[axis], Spacing: 0, Count: 1}
+		}
+	}
+	return grid, nil
+}
+
+/*
+	Builds a tile axis from major gridlines (see gridlines.DetectMajorGrid and DetectMajorGridByColor) of a fixed combined list.
+	Each major gridline starts a new tile, the first one at major.First. Cells are counted the same way
+	sampling.SampleRestoredImage counts them, so the result is valid for images sampled from fixed combined lists.
+	Returns zero TileAxis if the axis has no major grid.
+*/
+func TileAxisFromMajorGrid(fixed types.CombinedList, major gridlines.MajorGrid, edge_cell_min_fraction float32) TileAxis {
+	if major.Period < 2 {
+		return TileAxis{}
+	}
+	cells := sampling.GetPixelCellRanges(fixed, edge_cell_min_fraction)
+
+	// index of the first cell after the first major gridline
+	first_boundary := 0
+	for first_boundary < len(cells) && cells[first_boundary][0] < major.First {
+		first_boundary += 1
+	}
+	if first_boundary == len(cells) {
+		return TileAxis{}
+	}
+
+	offset := first_boundary % major.Period
+	return TileAxis{
+		Offset: offset,
+		Size: major.Period,
+		Spacing: 0,
+		Count: (len(cells) - offset) / major.Period,
+	}
+}
+
+/*
+	Returns [start, end) range of tile k on the axis
+*/
+func TileRange(axis TileAxis, k int) [2]int {
+	start := axis.Offset + k * (axis.Size + axis.Spacing)
+	return [2]int{start, start + axis.Size}
+}
+
+/*
+	For each column (vertical = true) or row of the image, returns colour of its first pixel
+	and whether all of its pixels are within tolerance of that colour.
+	Fully transparent pixels are treated as the same colour regardless of their RGB values.
+*/
+func uniformLines(img *image.RGBA, vertical bool, tolerance uint8) ([][4]uint8, []bool) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	line_count, line_length := height, width
+	if vertical {
+		line_count, line_length = width, height
+	}
+
+	colors := make([][4]uint8, line_count)
+	uniform := make([]bool, line_count)
+	for line := 0; line < line_count; line++ {
+		pixel_at := func(i int) [4]uint8 {
+			x, y := i, line
+			if vertical {
+				x, y = line, i
+			}
+			return common.PixelColor(img, x, y)
+		}
+
+		colors[line] = pixel_at(0)
+		uniform[line] = true
+		for i := 1; i < line_length && uniform[line]; i++ {
+			uniform[line] = common.ColorsClose(colors[line], pixel_at(i), tolerance)
+		}
+	}
+	return colors, uniform
+}
+
+/*
+	Returns the most common colour among uniform lines of both axes, false if there are no uniform lines
+*/
+func mostCommonColor(columns_colors [][4]uint8, columns_uniform []bool, rows_colors [][4]uint8, rows_uniform []bool) ([4]uint8, bool) {
+	counts := make(map[[4]uint8]int)
+	order := make([][4]uint8, 0, 8)
+	count_line := func(color [4]uint8, uniform bool) {
+		if !uniform {
+			return
+		}
+		if counts[color] == 0 {
+			order = append(order, color)
+		}
+		counts[color] += 1
+	}
+	for i := range columns_colors {
+		count_line(columns_colors[i], columns_uniform[i])
+	}
+	for i := range rows_colors {
+		count_line(rows_colors[i], rows_uniform[i])
+	}
+
+	var result [4]uint8
+	best := 0
+	for _, color := range order {
+		if counts[color] > best {
+			result, best = color, counts[color]
+		}
+	}
+	return result, best > 0
+}
+
+/*
+	Marks uniform lines of background colour
+*/
+func separatorLines(colors [][4]uint8, uniform []bool, background [4]uint8, tolerance uint8) []bool {
+	separators := make([]bool, len(colors))
+	for i := range colors {
+		separators[i] = uniform[i] && common.ColorsClose(colors[i], background, tolerance)
+	}
+	return separators
+}
+
+/*
+	Finds regularly repeating tiles between runs of separator lines.
+
+	Tile size is the most common length of spans between separator runs, spacing is the most common length of
+	separator runs that don't touch the image border. The first span of tile size decides the offset,
+	then the fraction of expected separators (between consecutive tiles) that are present must reach min_regularity.
+	Returns zero TileAxis if fewer than 2 tiles are found.
+*/
+func separatorTileAxis(separators []bool, min_regularity float64) TileAxis {
+	length := len(separators)
+
+	// runs of separator lines as [start, end) ranges
+	runs := make([][2]int, 0, 16)
+	for i := 0; i < length; i++ {
+		if !separators[i] {
+			continue
+		}
+		start := i
+		for i < length && separators[i] {
+			i += 1
+		}
+		runs = append(runs, [2]int{start, i})
+	}
+	if len(runs) == 0 {
+		return TileAxis{}
+	}
+
+	// spans between runs, including spans before the first and after the last run
+	spans := make([][2]int, 0, len(runs) + 1)
+	if runs[0][0] > 0 {
+		spans = append(spans, [2]int{0, runs[0][0]})
+	}
+	for i := 1; i < len(runs); i++ {
+		spans = append(spans, [2]int{runs[i - 1][1], runs[i][0]})
+	}
+	if runs[len(runs) - 1][1] < length {
+		spans = append(spans, [2]int{runs[len(runs) - 1][1], length})
+	}
+
+	span_lengths := make([]int, len(spans))
+	for i, span := range spans {
+		span_lengths[i] = span[1] - span[0]
+	}
+	inner_gutters := make([]int, 0, len(runs))
+	for _, run := range runs {
+		if run[0] > 0 && run[1] < length {
+			inner_gutters = append(inner_gutters, run[1] - run[0])
+		}
+	}
+	size, spacing := mostCommonValue(span_lengths), mostCommonValue(inner_gutters)
+	if size <= 0 || spacing <= 0 {
+		return TileAxis{}
+	}
+
+	offset := -1
+	for _, span := range spans {
+		if span[1] - span[0] == size {
+			offset = span[0]
+			break
+		}
+	}
+	// moving offset back while whole tiles fit before it
+	for offset - size - spacing >= 0 {
+		offset -= size + spacing
+	}
+
+	axis := TileAxis{Offset: offset, Size: size, Spacing: spacing, Count: (length - offset + spacing) / (size + spacing)}
+	if axis.Count < 2 {
+		return TileAxis{}
+	}
+
+	present := 0
+	for k := 0; k < axis.Count - 1; k++ {
+		gutter := [2]int{TileRange(axis, k)[1], TileRange(axis, k + 1)[0]}
+		var all_separators bool = true
+		for i := gutter[0]; i < gutter[1]; i++ {
+			all_separators = all_separators && separators[i]
+		}
+		if all_separators {
+			present += 1
+		}
+	}
+	if float64(present) / float64(axis.Count - 1) < min_regularity {
+		return TileAxis{}
+	}
+	return axis
+}
+
+/*
+	Returns the most common value of the slice, ties choose the smaller value. Returns 0 for empty slice.
+*/
+func mostCommonValue(values []int) int {
+	counts := make(map[int]int)
+	for _, value := range values {
+		counts[value] += 1
+	}
+	result, best := 0, 0
+	for value, count := range counts {
+		if count > best || count == best && value < result {
+			result, best = value, count
+		}
+	}
+	return result
+}
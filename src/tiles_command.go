@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+import (
+	"pixel_restoration/restore"
+	"pixel_restoration/tiles"
+)
+
+func commandTiles(args []string) int {
+	flags := flag.NewFlagSet("tiles", flag.ContinueOnError)
+	options := restore.GetBaseOptions()
+	apply_pipeline_flags := addPipelineFlags(flags, &options)
+	params := tiles.GetBaseTileParams()
+	output_dir := flags.String("dir", "tiles", "directory for tile images and atlas.json, created if missing")
+	tolerance := flags.Uint("tolerance", uint(params.ColorTolerance),
+		"maximum channel difference of colours of a uniform separator line (0 - 255)")
+	flags.Float64Var(&params.MinRegularity, "min-regularity", params.MinRegularity,
+		"fraction of separators between tiles that must be present for a tile period to be accepted")
+	keep_empty := flags.Bool("keep-empty", false, "also write tiles filled only with background colour")
+	min_confidence := addMinConfidenceFlag(flags)
+
+	input_path, code := parseCommandFlags(flags, args)
+	if code != EXIT_OK || input_path == "" {
+		return code
+	}
+	if err := apply_pipeline_flags(); err != nil {
+		fmt.Fprintf(os.Stderr, "tiles: %v\n", err)
+		return EXIT_USAGE
+	}
+	if *tolerance > 255 {
+		fmt.Fprintf(os.Stderr, "tiles: -tolerance must be at most 255, got %d\n", *tolerance)
+		return EXIT_USAGE
+	}
+	params.ColorTolerance = uint8(*tolerance)
+	params.SkipEmpty = !*keep_empty
+
+	result, code := loadAndRestore("tiles", input_path, options)
+	if code != EXIT_OK {
+		return code
+	}
+
+	// major gridlines can only be mapped to cells of images sampled from combined lists
+	var hints [2]tiles.TileAxis
+	if !options.SampleLattice {
+		hints = [2]tiles.TileAxis{
+			tiles.TileAxisFromMajorGrid(result.Rows.Fixed, result.Rows.Major, options.Sampling.EdgeCellMinFraction),
+			tiles.TileAxisFromMajorGrid(result.Cols.Fixed, result.Cols.Major, options.Sampling.EdgeCellMinFraction),
+		}
+	}
+	grid, err := tiles.DetectTiles(result.Image, hints, params)
+	if errors.Is(err, tiles.ErrNoTiles) {
+		fmt.Fprintf(os.Stderr, "tiles: %v\n", err)
+		return EXIT_DETECTION_FAILURE
+	}
+
+	split := tiles.SplitTiles(result.Image, grid, params)
+	atlas, err := tiles.WriteAtlas(*output_dir, input_path, result.Image, grid, split)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tiles: cannot write tiles: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	fmt.Printf("Tile size (width, height): %d %d\n", atlas.TileWidth, atlas.TileHeight)
+	fmt.Printf("Offset: %d %d, spacing: %d %d\n", atlas.OffsetX, atlas.OffsetY, atlas.SpacingX, atlas.SpacingY)
+	fmt.Printf("Grid (columns, rows): %d %d, written tiles: %d\n", atlas.Columns, atlas.Rows, len(atlas.Tiles))
+	return checkConfidence("tiles", result, *min_confidence)
+}
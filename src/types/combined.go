@@ -1,5 +1,13 @@
 package types
 
+import (
+	"fmt"
+)
+
+import (
+	"pixel_restoration/common"
+)
+
 /*
 
 	Combined list holds two attributes: Intevals and IntervalTypes
@@ -36,10 +44,15 @@ const (
 
 // func (*CombinedList) SquashItemTypes()
 
-func CombinedFromIntervalList(intervals IntervalList, guessed_params [2]IntervalRangeEntry) CombinedList {
+/*
+	Builds combined list from interval list and guessed pixel and gridline range entries.
+	Returns ErrTooFewEdges if interval list has fewer than 3 intervals (fewer than 2 edges).
+*/
+func CombinedFromIntervalList(intervals IntervalList, guessed_params [2]IntervalRangeEntry) (CombinedList, error) {
 	if len(intervals.Intervals) < 3 {
-		return CombinedList{[]uint{}, []uint8{}} 
-		//panic("This should never happen, guard against double zero antry and shorter than 3 items interval list")
+		return CombinedList{[]uint{}, []uint8{}}, fmt.Errorf(
+			"%w: %d intervals, at least 3 are required", common.ErrTooFewEdges, len(intervals.Intervals),
+		)
 	}
 
 	var case_grid_1_2 bool = guessed_params[1].Bounds[0] == 1 && guessed_params[1].Bounds[1] == 2
@@ -60,7 +73,7 @@ func CombinedFromIntervalList(intervals IntervalList, guessed_params [2]Interval
 	// fmt.Println("AFTER" ,interval_types)
 	// fmt.Println(new_intervals)
 
-	return CombinedList{new_intervals, interval_types} 	
+	return CombinedList{new_intervals, interval_types}, nil
 }
 
 
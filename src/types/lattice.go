@@ -0,0 +1,52 @@
+package types
+
+import (
+	"math"
+)
+
+/*
+	Lattice is a continuous model of a single image axis, unlike CombinedList it is not limited to whole pixels.
+
+	Period: float64
+		Distance between starts of two consecutive art pixels (pixel size + gridline width), for example 10.5
+	Phase: float64
+		Position of the first gridline start (or cell boundary if there are no gridlines), in range [0, Period)
+	GridWidth: float64
+		Width of gridlines, 0 if there are none
+	Coherence: float64
+		How well detected edges agree with the model (0.0 - 1.0), 0 means the lattice was not fitted to any edges
+
+	Gridline k occupies [Phase + k * Period, Phase + k * Period + GridWidth),
+	art pixel k occupies [Phase + k * Period + GridWidth, Phase + (k + 1) * Period).
+*/
+type Lattice struct {
+	Period float64
+	Phase float64
+	GridWidth float64
+	Coherence float64
+}
+
+/*
+	Returns [start, end) range of art pixel k, may lie partially or fully outside of the image
+*/
+func LatticeCellRange(lattice Lattice, k int) [2]float64 {
+	start := lattice.Phase + float64(k) * lattice.Period
+	return [2]float64{start + lattice.GridWidth, start + lattice.Period}
+}
+
+/*
+	Returns index of the first art pixel whose range ends after position 0
+*/
+func LatticeFirstCell(lattice Lattice) int {
+	return int(math.Floor(-lattice.Phase / lattice.Period))
+}
+
+/*
+	Returns a copy of the lattice with all lengths multiplied by factor
+*/
+func LatticeScaled(lattice Lattice, factor float64) Lattice {
+	lattice.Period *= factor
+	lattice.Phase *= factor
+	lattice.GridWidth *= factor
+	return lattice
+}
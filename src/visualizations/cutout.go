@@ -1,5 +1,6 @@
 package visualizations
 
+import "errors"
 import "image"
 
 import "pixel_restoration/images"
@@ -7,9 +8,10 @@ import "pixel_restoration/types"
 
 /* 
 	Creates copy of an image and draws gridlines at all unknown positions and at all positions where interval type is gridline
+	Returns ErrIndexOutOfRange if combined lists are longer than the image.
 */
 func ImageWithDrawnCutoutSimple(img *image.RGBA, combined_lists[2] types.CombinedList,
-									 color_unknown, color_gridline [4] uint8) *image.RGBA {
+									 color_unknown, color_gridline [4] uint8) (*image.RGBA, error) {
 	new_img := images.ImageGetNormalized(img)
 
 	unknowns_cols := getIndexesOfUnknowns(combined_lists[0])
@@ -18,13 +20,17 @@ func ImageWithDrawnCutoutSimple(img *image.RGBA, combined_lists[2] types.Combine
 	grids_cols := getIndexesOfGrid(combined_lists[0])
 	grids_rows := getIndexesOfGrid(combined_lists[1])
 
-	images.DrawGridlineRowsOnImage(new_img, unknowns_cols, color_unknown)
-	images.DrawGridlineColsOnImage(new_img, unknowns_rows, color_unknown)
-
-	images.DrawGridlineRowsOnImage(new_img, grids_cols, color_gridline)
-	images.DrawGridlineColsOnImage(new_img, grids_rows, color_gridline)
+	err := errors.Join(
+		images.DrawGridlineRowsOnImage(new_img, unknowns_cols, color_unknown),
+		images.DrawGridlineColsOnImage(new_img, unknowns_rows, color_unknown),
+		images.DrawGridlineRowsOnImage(new_img, grids_cols, color_gridline),
+		images.DrawGridlineColsOnImage(new_img, grids_rows, color_gridline),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	return new_img
+	return new_img, nil
 }
 
 
@@ -78,9 +84,10 @@ func getIndexesOfGrid(combined_list types.CombinedList)[]int{
 
 /* 
 	Creates copy of an image and draws gridlines at all unknown positions and at all positions where interval type is gridline
+	Returns ErrIndexOutOfRange if combined lists are longer than the image.
 */
 func ImageWithDrawnCutoutSimpleWithZeros(img *image.RGBA, combined_lists[2] types.CombinedList,
-									 	color_unknown, color_gridline [4] uint8) *image.RGBA {
+									 	color_unknown, color_gridline [4] uint8) (*image.RGBA, error) {
 	new_img := images.ImageGetNormalized(img)
 
 	unknowns_cols := getIndexesOfUnknownsWithZeros(combined_lists[0])
@@ -89,13 +96,17 @@ func ImageWithDrawnCutoutSimpleWithZeros(img *image.RGBA, combined_lists[2] type
 	grids_cols := getIndexesOfGridWithZeros(combined_lists[0])
 	grids_rows := getIndexesOfGridWithZeros(combined_lists[1])
 
-	images.DrawGridlineRowsOnImage(new_img, unknowns_cols, color_unknown)
-	images.DrawGridlineColsOnImage(new_img, unknowns_rows, color_unknown)
-
-	images.DrawGridlineRowsOnImage(new_img, grids_cols, color_gridline)
-	images.DrawGridlineColsOnImage(new_img, grids_rows, color_gridline)
+	err := errors.Join(
+		images.DrawGridlineRowsOnImage(new_img, unknowns_cols, color_unknown),
+		images.DrawGridlineColsOnImage(new_img, unknowns_rows, color_unknown),
+		images.DrawGridlineRowsOnImage(new_img, grids_cols, color_gridline),
+		images.DrawGridlineColsOnImage(new_img, grids_rows, color_gridline),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	return new_img
+	return new_img, nil
 }
 
 func getIndexesOfUnknownsWithZeros(combined_list types.CombinedList)[]int{
@@ -1,5 +1,6 @@
 package visualizations
 
+import "errors"
 import "image"
 
 import "pixel_restoration/images"
@@ -7,27 +8,37 @@ import "pixel_restoration/types"
 
 /* 
 	Creates copy of an image and draws gridlines at selected position indexes with given color
+	Returns ErrIndexOutOfRange if any index lies outside of the image.
 */
-func ImageWithDrawnGridlinesSimple(img *image.RGBA, indexes[2][]int, color [4]uint8) *image.RGBA {
+func ImageWithDrawnGridlinesSimple(img *image.RGBA, indexes[2][]int, color [4]uint8) (*image.RGBA, error) {
 	new_img := images.ImageGetNormalized(img)
 
-	images.DrawGridlineRowsOnImage(new_img, indexes[0], color)
-	images.DrawGridlineColsOnImage(new_img, indexes[1], color)
+	err := errors.Join(
+		images.DrawGridlineRowsOnImage(new_img, indexes[0], color),
+		images.DrawGridlineColsOnImage(new_img, indexes[1], color),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	return new_img
+	return new_img, nil
 }
 
 /*
 	 Creates an upscaled copy of an image and draws black gridlines between each pixel of original image
 
 	 Draws <color> gridlines after selected pixels represented by positions from original image
+	 Returns ErrIndexOutOfRange if any index lies outside of the image.
 */
-func ImageWithDrawnGridlinesAdvanced(img *image.RGBA, indexes[2][]int, color [4]uint8) *image.RGBA{
+func ImageWithDrawnGridlinesAdvanced(img *image.RGBA, indexes[2][]int, color [4]uint8) (*image.RGBA, error){
 	const pixel_size = 5
 	const grid_size = 1
 	color_black := [4]uint8{0,0,0,255}
 
-	var img_big *image.RGBA = images.AdvancedUpscaleGetNewImage(img, pixel_size, grid_size, color_black)
+	img_big, err := images.AdvancedUpscaleGetNewImage(img, pixel_size, grid_size, color_black)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Drawing selected edges in provided color
 	indexes_scaled := [2][]int{
@@ -35,10 +46,15 @@ func ImageWithDrawnGridlinesAdvanced(img *image.RGBA, indexes[2][]int, color [4]
 		indexesConvertToScaled(indexes[1], pixel_size),
 	}
 
-	images.DrawGridlineRowsOnImage(img_big, indexes_scaled[0], color)
-	images.DrawGridlineColsOnImage(img_big, indexes_scaled[1], color)
+	err = errors.Join(
+		images.DrawGridlineRowsOnImage(img_big, indexes_scaled[0], color),
+		images.DrawGridlineColsOnImage(img_big, indexes_scaled[1], color),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	return img_big
+	return img_big, nil
 
 }
 
@@ -48,19 +64,23 @@ func ImageWithDrawnGridlinesAdvanced(img *image.RGBA, indexes[2][]int, color [4]
 	Blocks of pixels (and edges) that represent unknown items are colored according to <color_unknown>
 	Blocks of pixels (and edges) that represent grid items are colored according to <color_gridline> 
 	Gridline colors are applied on top of unknown colors. 
+	Returns ErrIndexOutOfRange if combined lists are longer than the image.
 
 */
 
 func ImageWithDrawnCombinedListAdvanced(
 	img *image.RGBA, combined_lists[2] types.CombinedList, color_unknown, color_gridline [4]uint8,
-) *image.RGBA {
+) (*image.RGBA, error) {
 
 	// obtain upscaled image with black grid
 	const pixel_size = 5
 	const grid_size = 1
 	color_black := [4]uint8{0,0,0,255}
 
-	var img_big *image.RGBA = images.AdvancedUpscaleGetNewImage(img, pixel_size, grid_size, color_black)
+	img_big, err := images.AdvancedUpscaleGetNewImage(img, pixel_size, grid_size, color_black)
+	if err != nil {
+		return nil, err
+	}
 
 	unknown_ranges := [2][][2]int{
 		getIntervalTypePixelRanges(combined_lists[0], types.INTERVAL_UNKNOWN),
@@ -75,8 +95,13 @@ func ImageWithDrawnCombinedListAdvanced(
 		scaledRangesToIndexes(unknown_ranges_scaled[1]),
 	}
 
-	images.DrawGridlineRowsOnImage(img_big, unknown_indexes[0], color_unknown)
-	images.DrawGridlineColsOnImage(img_big, unknown_indexes[1], color_unknown)
+	err = errors.Join(
+		images.DrawGridlineRowsOnImage(img_big, unknown_indexes[0], color_unknown),
+		images.DrawGridlineColsOnImage(img_big, unknown_indexes[1], color_unknown),
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	grid_ranges := [2][][2]int{
 		getIntervalTypePixelRanges(combined_lists[0], types.INTERVAL_GRID),
@@ -93,10 +118,15 @@ func ImageWithDrawnCombinedListAdvanced(
 		scaledRangesToIndexes(grid_ranges_scaled[0]),
 		scaledRangesToIndexes(grid_ranges_scaled[1]),
 	}
-	images.DrawGridlineRowsOnImage(img_big, grid_indexes[0], color_gridline)
-	images.DrawGridlineColsOnImage(img_big, grid_indexes[1], color_gridline)
+	err = errors.Join(
+		images.DrawGridlineRowsOnImage(img_big, grid_indexes[0], color_gridline),
+		images.DrawGridlineColsOnImage(img_big, grid_indexes[1], color_gridline),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	return img_big
+	return img_big, nil
 }
 
 /*
@@ -1,8 +1,10 @@
 package visualizations
 
 
+import "fmt"
 import "image"
 
+import "pixel_restoration/common"
 import "pixel_restoration/images"
 
 /*
@@ -11,12 +13,13 @@ import "pixel_restoration/images"
 	As long as provided images have width < 2 * height, images are arranged side by side
 	Otherwise images are arranged up and below
 
-	Input images must have the same dimensions
+	Input images must have the same dimensions, otherwise ErrAxisMismatch is returned
 
 */
-func SideBySideGrayscale(img1, img2 *image.Gray) *image.Gray{
+func SideBySideGrayscale(img1, img2 *image.Gray) (*image.Gray, error){
 	if img1.Rect.Dx() != img2.Rect.Dx() || img1.Rect.Dy() != img2.Rect.Dy(){
-		panic("Different sized grayscale images provided")
+		return nil, fmt.Errorf("%w: different sized grayscale images provided (%v and %v)",
+			common.ErrAxisMismatch, img1.Rect.Size(), img2.Rect.Size())
 	}
 
 	img1 = images.GrayscaleGetNormalized(img1)
@@ -24,9 +27,9 @@ func SideBySideGrayscale(img1, img2 *image.Gray) *image.Gray{
 
 	height, width := img1.Rect.Dy(), img1.Rect.Dx()
 	if  width > height * 2 {
-		return combineVertically(img1, img2)
+		return combineVertically(img1, img2), nil
 	}else{
-		return combineHorizontally(img1, img2)
+		return combineHorizontally(img1, img2), nil
 	}
 
 